@@ -0,0 +1,94 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"backend/models"
+)
+
+var (
+	ErrUnknownCarrier     = errors.New("carrier is not supported")
+	ErrQuoteNotSupported  = errors.New("carrier does not quote deliveries")
+	ErrTrackingNotFound   = errors.New("carrier does not know the tracking number")
+	ErrCantCancel         = errors.New("carrier cannot cancel a parcel it already picked up")
+	ErrServiceUnavailable = errors.New("carrier does not offer this delivery option")
+)
+
+// Manual is the carrier of shipments handed to a delivery company outside of
+// the store, whose tracking the admin records by hand.
+const Manual = "manual"
+
+// Parcel is a package to quote or ship.
+type Parcel struct {
+	// Option is the delivery option, standard or express.
+	Option string
+	To     models.Address
+	// Weight is in grams.
+	Weight uint64
+	// Value is what the goods are worth.
+	Value uint64
+	// TrackingNumber is the number a carrier that does not issue its own
+	// gave the parcel.
+	TrackingNumber string
+}
+
+type Quote struct {
+	Cost    uint64
+	MinDays int
+	MaxDays int
+}
+
+// Label is what a carrier issues for a parcel it agreed to carry.
+type Label struct {
+	TrackingNumber string
+	// LabelRef identifies the shipping label to print, such as its URL.
+	LabelRef string
+}
+
+// Carrier is a delivery company the store ships through.
+type Carrier interface {
+	Name() string
+	Quote(ctx context.Context, parcel Parcel) (Quote, error)
+	CreateLabel(ctx context.Context, parcel Parcel) (Label, error)
+	// Track lists the tracking events of the parcel so far, oldest first.
+	Track(ctx context.Context, trackingNumber string) ([]models.TrackingEvent, error)
+	// Cancel calls off a parcel that has not been picked up yet.
+	Cancel(ctx context.Context, trackingNumber string) error
+}
+
+var carriers = enabledCarriers(os.Getenv("CARRIERS"))
+
+// enabledCarriers builds the carriers named in the comma separated list. The
+// manual carrier is always available.
+func enabledCarriers(names string) map[string]Carrier {
+	enabled := map[string]Carrier{Manual: ManualCarrier{}}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case MockName:
+			enabled[MockName] = NewMock(os.Getenv("MOCK_CARRIER_STEP"))
+		}
+	}
+	return enabled
+}
+
+func Get(name string) (Carrier, error) {
+	carrier, ok := carriers[name]
+	if !ok {
+		return nil, ErrUnknownCarrier
+	}
+	return carrier, nil
+}
+
+// Names lists the names of the enabled carriers.
+func Names() []string {
+	names := make([]string, 0, len(carriers))
+	for name := range carriers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
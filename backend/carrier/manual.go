@@ -0,0 +1,31 @@
+package carrier
+
+import (
+	"context"
+
+	"backend/models"
+)
+
+// ManualCarrier ships with the tracking number the admin was given by the
+// delivery company and leaves recording the tracking events to them.
+type ManualCarrier struct{}
+
+func (ManualCarrier) Name() string {
+	return Manual
+}
+
+func (ManualCarrier) Quote(ctx context.Context, parcel Parcel) (Quote, error) {
+	return Quote{}, ErrQuoteNotSupported
+}
+
+func (ManualCarrier) CreateLabel(ctx context.Context, parcel Parcel) (Label, error) {
+	return Label{TrackingNumber: parcel.TrackingNumber}, nil
+}
+
+func (ManualCarrier) Track(ctx context.Context, trackingNumber string) ([]models.TrackingEvent, error) {
+	return nil, nil
+}
+
+func (ManualCarrier) Cancel(ctx context.Context, trackingNumber string) error {
+	return nil
+}
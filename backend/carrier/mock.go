@@ -0,0 +1,118 @@
+package carrier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+const MockName = "mock"
+
+// DefaultMockStep is how long the mock carrier takes between tracking events.
+const DefaultMockStep = time.Minute
+
+// Mock is a carrier for local development. Its parcels move on by themselves:
+// one step after the label is created they are in transit, then out for
+// delivery, then delivered. The label time is kept in the tracking number, so
+// tracking survives restarts; cancellations are only kept in memory.
+type Mock struct {
+	step      time.Duration
+	mu        sync.Mutex
+	cancelled map[string]time.Time
+}
+
+// NewMock builds the mock carrier, stepping as often as step says, a duration
+// such as "30s", or DefaultMockStep.
+func NewMock(step string) *Mock {
+	duration, err := time.ParseDuration(step)
+	if err != nil || duration <= 0 {
+		duration = DefaultMockStep
+	}
+	return &Mock{step: duration, cancelled: make(map[string]time.Time)}
+}
+
+func (m *Mock) Name() string {
+	return MockName
+}
+
+// Quote charges 20,000 plus 5,000 for every started kilogram, twice that for
+// express.
+func (m *Mock) Quote(ctx context.Context, parcel Parcel) (Quote, error) {
+	cost := 20000 + 5000*((parcel.Weight+999)/1000)
+	switch parcel.Option {
+	case models.DeliveryStandard, "":
+		return Quote{Cost: cost, MinDays: 2, MaxDays: 4}, nil
+	case models.DeliveryExpress:
+		return Quote{Cost: 2 * cost, MinDays: 1, MaxDays: 1}, nil
+	}
+	return Quote{}, ErrServiceUnavailable
+}
+
+func (m *Mock) CreateLabel(ctx context.Context, parcel Parcel) (Label, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return Label{}, err
+	}
+	number := fmt.Sprintf("MK%d%s", time.Now().Unix(), strings.ToUpper(hex.EncodeToString(suffix)))
+	return Label{TrackingNumber: number, LabelRef: "mock://labels/" + number}, nil
+}
+
+// labelTime reads the time the label was created from its tracking number.
+func labelTime(trackingNumber string) (time.Time, bool) {
+	if !strings.HasPrefix(trackingNumber, "MK") || len(trackingNumber) != 18 {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(trackingNumber[2:12], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+func (m *Mock) Track(ctx context.Context, trackingNumber string) ([]models.TrackingEvent, error) {
+	created, ok := labelTime(trackingNumber)
+	if !ok {
+		return nil, ErrTrackingNotFound
+	}
+	m.mu.Lock()
+	cancelledAt, cancelled := m.cancelled[trackingNumber]
+	m.mu.Unlock()
+
+	steps := []models.TrackingEvent{
+		{Status: models.ShipmentInTransit, Description: "Picked up by the carrier", Location: "Mock sorting centre"},
+		{Status: models.ShipmentOutForDelivery, Description: "Out for delivery", Location: "Mock local depot"},
+		{Status: models.ShipmentDelivered, Description: "Delivered to the recipient"},
+	}
+	events := make([]models.TrackingEvent, 0, len(steps))
+	now := time.Now()
+	for i, event := range steps {
+		event.OccurredAt = created.Add(time.Duration(i+1) * m.step)
+		if event.OccurredAt.After(now) || (cancelled && event.OccurredAt.After(cancelledAt)) {
+			break
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (m *Mock) Cancel(ctx context.Context, trackingNumber string) error {
+	created, ok := labelTime(trackingNumber)
+	if !ok {
+		return ErrTrackingNotFound
+	}
+	now := time.Now()
+	if !now.Before(created.Add(m.step)) {
+		return ErrCantCancel
+	}
+	m.mu.Lock()
+	m.cancelled[trackingNumber] = now
+	m.mu.Unlock()
+	return nil
+}
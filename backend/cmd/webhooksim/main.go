@@ -0,0 +1,84 @@
+// Command webhooksim sends signed fake card webhook events to a running server,
+// to try out payment confirmations without a real provider.
+//
+//	go run ./cmd/webhooksim -ref fake_pi_<order id> -status captured
+//
+// The secret defaults to FAKE_CARD_WEBHOOK_SECRET and must match the server's.
+// Use -repeat to deliver the same event more than once and -age to send a
+// signature from the past.
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"backend/models"
+	"backend/payment"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8000/payments/webhook/"+models.PaymentFakeCard, "webhook endpoint of the server")
+	secret := flag.String("secret", os.Getenv("FAKE_CARD_WEBHOOK_SECRET"), "webhook signing secret")
+	ref := flag.String("ref", "", "provider reference of the payment, as stored on its intent")
+	status := flag.String("status", string(models.PaymentCaptured), "payment status to report: captured, failed, voided, partially_refunded or refunded")
+	amount := flag.Uint64("amount", 0, "total amount refunded so far, for refund events")
+	reason := flag.String("reason", "", "failure reason, for failed events")
+	eventId := flag.String("event", "", "event id; a random one is generated when empty")
+	repeat := flag.Int("repeat", 1, "number of times to deliver the event")
+	age := flag.Duration("age", 0, "how long ago the signature claims to have been made")
+	flag.Parse()
+
+	if *ref == "" {
+		log.Fatal("-ref is required")
+	}
+	if *eventId == "" {
+		*eventId = "evt_" + randomHex(12)
+	}
+	event := models.PaymentEvent{
+		EventId:       *eventId,
+		Type:          "payment." + *status,
+		ProviderRef:   *ref,
+		Status:        models.PaymentStatus(*status),
+		Amount:        *amount,
+		FailureReason: *reason,
+		OccurredAt:    time.Now(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i := 0; i < *repeat; i++ {
+		signature := payment.SignFakeCardWebhook(*secret, payload, time.Now().Add(-*age))
+		request, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(payload))
+		if err != nil {
+			log.Fatal(err)
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Webhook-Signature", signature)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			log.Fatal(err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		fmt.Printf("%s delivery %d: %s\n%s\n", event.EventId, i+1, response.Status, body)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -2,247 +2,144 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
 	"time"
 
+	"backend/database"
+	"backend/divisions"
 	"backend/models"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func AddAddress() gin.HandlerFunc {
+func addressErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrAddressNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrUserIdIsNotValid):
+		return http.StatusBadRequest
+	case errors.Is(err, divisions.ErrUnknownProvince), errors.Is(err, divisions.ErrDistrictNotInProvince),
+		errors.Is(err, divisions.ErrWardNotInDistrict), errors.Is(err, divisions.ErrDivisionsNotListed):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func GetAddresses() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
-		userId := c.Query("userId")
-		if userId == "" {
-			c.Header("Content-Type", "application/json")
-			response.Status = "Failed"
-			response.Code = http.StatusNotFound
-			response.Msg = "Missing userId"
-			c.IndentedJSON(http.StatusNotFound, response)
-			c.Abort()
-			return
-		}
-		address, err := primitive.ObjectIDFromHex(userId)
-		if err != nil {
-			response.Status = "Failed"
-			response.Code = 500
-			response.Msg = "Internal Server Error"
-			c.IndentedJSON(500, response)
-			return
-		}
-		var addresses models.Address
-		addresses.AddressId = primitive.NewObjectID()
-		if err = c.BindJSON(&addresses); err != nil {
-			response.Status = "Failed"
-			response.Code = http.StatusNotAcceptable
-			response.Msg = "Invalid address"
-			c.IndentedJSON(http.StatusNotAcceptable, response)
-			return
-		}
-
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		matchFilter := bson.D{{Key: "$match", Value: bson.D{primitive.E{Key: "_id", Value: address}}}}
-		unwind := bson.D{{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$addresses"}}}}
-		group := bson.D{{Key: "$group", Value: bson.D{primitive.E{Key: "_id", Value: "$address_id"}, {Key: "count", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}}}}}
-
-		pointCursor, err := UserCollection.Aggregate(ctx, mongo.Pipeline{matchFilter, unwind, group})
+		book, err := database.ListAddresses(ctx, UserCollection, c.GetString("uid"))
 		if err != nil {
+			code := addressErrorCode(err)
 			response.Status = "Failed"
-			response.Code = 500
-			response.Msg = "Internal Server Error"
-			c.IndentedJSON(500, response)
-			return
-		}
-
-		var addressInfo []bson.M
-		if err = pointCursor.All(ctx, &addressInfo); err != nil {
-			panic(err)
-		}
-
-		var size int32
-		for _, address_no := range addressInfo {
-			count := address_no["count"]
-			size = count.(int32)
-		}
-		if size < 2 {
-			filter := bson.D{primitive.E{Key: "_id", Value: address}}
-			update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "addresses", Value: addresses}}}}
-			_, err := UserCollection.UpdateOne(ctx, filter, update)
-			if err != nil {
-				fmt.Println(err)
-			}
-		} else {
-			response.Status = "Failed"
-			response.Code = 400
-			response.Msg = "Not allowed"
-			c.IndentedJSON(400, response)
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
 			return
 		}
 
-		ctx.Done()
-
 		response.Status = "OK"
-		response.Code = http.StatusOK
+		response.Code = 200
 		response.Msg = "Successfully"
-		c.IndentedJSON(http.StatusOK, response)
+		response.Data = book
+		c.IndentedJSON(200, response)
 		return
 	}
 }
 
-func EditHomeAddress() gin.HandlerFunc {
+// saveAddressHandler binds and validates an address request and hands it to
+// save, responding with the resulting address book.
+func saveAddressHandler(code int, msg string, save func(ctx context.Context, c *gin.Context, request models.AddressRequest) (models.AddressBook, error)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
-		userId := c.Query("userId")
-		if userId == "" {
-			c.Header("Content-Type", "application/json")
-
+		var request models.AddressRequest
+		if err := c.BindJSON(&request); err != nil {
 			response.Status = "Failed"
-			response.Code = http.StatusNotFound
-			response.Msg = "Invalid"
-			c.IndentedJSON(http.StatusNotFound, response)
-			c.Abort()
-			return
-		}
-		usertId, err := primitive.ObjectIDFromHex(userId)
-		if err != nil {
-			response.Status = "Failed"
-			response.Code = 500
+			response.Code = http.StatusBadRequest
 			response.Msg = err.Error()
-			c.IndentedJSON(500, response)
+			c.IndentedJSON(http.StatusBadRequest, response)
 			return
 		}
-		var editAddress models.Address
-		if err := c.BindJSON(&editAddress); err != nil {
+		if validationErr := Validate.Struct(request); validationErr != nil {
 			response.Status = "Failed"
 			response.Code = http.StatusBadRequest
-			response.Msg = err.Error()
+			response.Msg = validationErr.Error()
 			c.IndentedJSON(http.StatusBadRequest, response)
 			return
 		}
+
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		filter := bson.D{primitive.E{Key: "_id", Value: usertId}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "addresses.0.house", Value: editAddress.House}, {Key: "addresses.0.street", Value: editAddress.Street}, {Key: "addresses.0.ward", Value: editAddress.Ward}, {Key: "addresses.0.district", Value: editAddress.District}, {Key: "addresses.0.city", Value: editAddress.City}}}}
-		_, err = UserCollection.UpdateOne(ctx, filter, update)
+
+		book, err := save(ctx, c, request)
 		if err != nil {
+			code := addressErrorCode(err)
 			response.Status = "Failed"
-			response.Code = 500
-			response.Msg = "Something went wrong"
-			c.IndentedJSON(500, response)
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
 			return
 		}
 
-		ctx.Done()
-
 		response.Status = "OK"
-		response.Code = 200
-		response.Msg = "Successfully updated home address"
-		c.IndentedJSON(200, response)
+		response.Code = uint(code)
+		response.Msg = msg
+		response.Data = book
+		c.IndentedJSON(code, response)
 		return
 	}
 }
 
-func EditWorkAddress() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var response models.Response
-		userId := c.Query("userId")
-		if userId == "" {
-			c.Header("Content-Type", "application/json")
+func AddAddress() gin.HandlerFunc {
+	return saveAddressHandler(http.StatusCreated, "Successfully added the address", func(ctx context.Context, c *gin.Context, request models.AddressRequest) (models.AddressBook, error) {
+		return database.AddAddress(ctx, UserCollection, c.GetString("uid"), request)
+	})
+}
 
-			response.Status = "Failed"
-			response.Code = http.StatusNotFound
-			response.Msg = "Missing userId"
-			c.IndentedJSON(http.StatusNotFound, response)
-			c.Abort()
-			return
-		}
-		usertId, err := primitive.ObjectIDFromHex(userId)
+func EditAddress() gin.HandlerFunc {
+	return saveAddressHandler(http.StatusOK, "Successfully updated the address", func(ctx context.Context, c *gin.Context, request models.AddressRequest) (models.AddressBook, error) {
+		addressId, err := primitive.ObjectIDFromHex(c.Param("id"))
 		if err != nil {
-			response.Status = "Failed"
-			response.Code = 500
-			response.Msg = err.Error()
-			c.IndentedJSON(500, response)
-			return
+			return models.AddressBook{}, database.ErrAddressNotFound
 		}
-		var editAddress models.Address
-		if err := c.BindJSON(&editAddress); err != nil {
-			response.Status = "Failed"
-			response.Code = http.StatusBadRequest
-			response.Msg = err.Error()
-			c.IndentedJSON(http.StatusBadRequest, response)
-			return
-		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
-		filter := bson.D{primitive.E{Key: "_id", Value: usertId}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "addresses.1.house", Value: editAddress.House}, {Key: "addresses.1.street", Value: editAddress.Street}, {Key: "addresses.1.ward", Value: editAddress.Ward}, {Key: "addresses.1.district", Value: editAddress.District}, {Key: "addresses.1.city", Value: editAddress.City}}}}
-		_, err = UserCollection.UpdateOne(ctx, filter, update)
-		if err != nil {
-			response.Status = "Failed"
-			response.Code = 500
-			response.Msg = "Something went wrong"
-			c.IndentedJSON(500, response)
-			return
-		}
-
-		ctx.Done()
-
-		response.Status = "OK"
-		response.Code = 200
-		response.Msg = "Successfully updated work address"
-		c.IndentedJSON(200, response)
-		return
-	}
+		return database.UpdateAddress(ctx, UserCollection, c.GetString("uid"), addressId, request)
+	})
 }
 
 func DeleteAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
-		userId := c.Query("userId")
-		if userId == "" {
-			c.Header("Content-Type", "application/json")
-
+		addressId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
 			response.Status = "Failed"
 			response.Code = http.StatusNotFound
-			response.Msg = "Invalid searched index"
+			response.Msg = database.ErrAddressNotFound.Error()
 			c.IndentedJSON(http.StatusNotFound, response)
-			c.Abort()
-			return
-		}
-		addresses := make([]models.Address, 0)
-		usertId, err := primitive.ObjectIDFromHex(userId)
-		if err != nil {
-			response.Status = "Failed"
-			response.Code = 500
-			response.Msg = "Internal Server Error"
-			c.IndentedJSON(500, response)
 			return
 		}
+
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		filter := bson.D{primitive.E{Key: "_id", Value: usertId}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "addresses", Value: addresses}}}}
-		_, err = UserCollection.UpdateOne(ctx, filter, update)
+
+		book, err := database.DeleteAddress(ctx, UserCollection, c.GetString("uid"), addressId)
 		if err != nil {
+			code := addressErrorCode(err)
 			response.Status = "Failed"
-			response.Code = 404
-			response.Msg = "Wrong"
-			c.IndentedJSON(404, response)
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
 			return
 		}
 
-		ctx.Done()
-
 		response.Status = "OK"
 		response.Code = 200
-		response.Msg = "Successfully deleted the address(es)"
+		response.Msg = "Successfully deleted the address"
+		response.Data = book
 		c.IndentedJSON(200, response)
 		return
 	}
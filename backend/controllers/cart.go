@@ -5,10 +5,13 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"backend/database"
+	"backend/divisions"
 	"backend/models"
+	"backend/pricing"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -20,13 +23,19 @@ type Application struct {
 	productCollection *mongo.Collection
 	userCollection    *mongo.Collection
 	orderCollection   *mongo.Collection
+	paymentCollection *mongo.Collection
+	counterCollection *mongo.Collection
+	pricer            *database.CartPricer
 }
 
-func NewApplication(productCollection *mongo.Collection, userCollection *mongo.Collection, orderCollection *mongo.Collection) *Application {
+func NewApplication(productCollection *mongo.Collection, userCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, counterCollection *mongo.Collection, pricer *database.CartPricer) *Application {
 	return &Application{
 		productCollection: productCollection,
 		userCollection:    userCollection,
 		orderCollection:   orderCollection,
+		paymentCollection: paymentCollection,
+		counterCollection: counterCollection,
+		pricer:            pricer,
 	}
 }
 
@@ -39,22 +48,24 @@ func (app *Application) AddToCart() gin.HandlerFunc {
 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("product id is empty"))
 			return
 		}
-		userQueryId := c.Query("userId")
-		if userQueryId == "" {
-			log.Println("user id is empty")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("user id is empty"))
-			return
-		}
 		productId, err := primitive.ObjectIDFromHex(productQueryId)
 		if err != nil {
 			log.Println(err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+		quantity, err := cartQuantity(c)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Invalid quantity"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		err = database.AddProductToCart(ctx, app.productCollection, app.userCollection, productId, userQueryId)
+		err = database.AddProductToCart(ctx, app.productCollection, app.userCollection, productId, c.GetString("uid"), quantity)
 		if err != nil {
 			response.Status = "Failed"
 			response.Code = http.StatusInternalServerError
@@ -71,6 +82,23 @@ func (app *Application) AddToCart() gin.HandlerFunc {
 	}
 }
 
+// cartQuantity reads the optional quantity query parameter of the add-to-cart
+// routes, defaulting to a single unit.
+func cartQuantity(c *gin.Context) (uint64, error) {
+	quantityString := c.Query("quantity")
+	if quantityString == "" {
+		return 1, nil
+	}
+	quantity, err := strconv.ParseUint(quantityString, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if quantity == 0 || quantity > models.MaxCartQuantity {
+		return 0, errors.New("quantity must be between 1 and " + strconv.Itoa(models.MaxCartQuantity))
+	}
+	return quantity, nil
+}
+
 func (app *Application) RemoveItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
@@ -81,12 +109,6 @@ func (app *Application) RemoveItem() gin.HandlerFunc {
 			return
 		}
 
-		userQueryId := c.Query("userId")
-		if userQueryId == "" {
-			log.Println("Missing user id")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Missing user id"))
-		}
-
 		productId, err := primitive.ObjectIDFromHex(productQueryId)
 		if err != nil {
 			log.Println(err)
@@ -96,7 +118,7 @@ func (app *Application) RemoveItem() gin.HandlerFunc {
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		err = database.RemoveCartItem(ctx, app.productCollection, app.userCollection, productId, userQueryId)
+		err = database.RemoveCartItem(ctx, app.productCollection, app.userCollection, productId, c.GetString("uid"))
 		if err != nil {
 			response.Status = "Failed"
 			response.Code = http.StatusInternalServerError
@@ -116,19 +138,7 @@ func (app *Application) RemoveItem() gin.HandlerFunc {
 func GetItemsFromCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
-		userId := c.Query("userId")
-		if userId == "" {
-			c.Header("Content-Type", "application/json")
-
-			response.Status = "Failed"
-			response.Code = http.StatusNotFound
-			response.Msg = "Missing userId"
-			c.IndentedJSON(http.StatusNotFound, response)
-			c.Abort()
-			return
-		}
-
-		usertId, _ := primitive.ObjectIDFromHex(userId)
+		usertId, _ := primitive.ObjectIDFromHex(c.GetString("uid"))
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -145,94 +155,180 @@ func GetItemsFromCart() gin.HandlerFunc {
 			return
 		}
 
-		filterMatch := bson.D{{Key: "$match", Value: bson.D{primitive.E{Key: "_id", Value: usertId}}}}
-		unwind := bson.D{{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$user_cart"}}}}
-		grouping := bson.D{{Key: "$group", Value: bson.D{primitive.E{Key: "_id", Value: "$_id"}, {Key: "total", Value: bson.D{primitive.E{Key: "$sum", Value: "$user_cart.price"}}}}}}
-		pointCursor, err := UserCollection.Aggregate(ctx, mongo.Pipeline{filterMatch, unwind, grouping})
+		cart, changes, err := database.ResolveCart(ctx, ProductCollection, filledCart.UserCart)
 		if err != nil {
-			log.Println(err)
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
 		}
-		var listing []bson.M
-		if err = pointCursor.All(ctx, &listing); err != nil {
-			log.Println(err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+
+		address, err := database.ShippingAddress(filledCart, c.Query("addressId"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
 		}
 
-		for _, json := range listing {
-			response.Status = "OK"
-			response.Code = 200
-			response.Msg = json["total"]
-			response.Data = filledCart.UserCart
-			c.IndentedJSON(200, response)
+		request := database.PriceRequest{
+			UserId:         c.GetString("uid"),
+			CouponCode:     filledCart.AppliedCoupon,
+			Address:        address,
+			DeliveryOption: c.Query("deliveryOption"),
+		}
+		priced, err := Pricer.PriceCart(ctx, request, cart)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
 			return
 		}
+		data := gin.H{"items": cart, "changes": changes, "fingerprint": database.CartFingerprint(cart), "pricing": priced.Breakdown}
+		if priced.CouponErr != nil {
+			data["coupon_error"] = priced.CouponErr.Error()
+		}
+		if priced.DeliveryErr != nil {
+			data["delivery_error"] = priced.DeliveryErr.Error()
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = data
+		c.IndentedJSON(200, response)
+		return
+	}
+}
 
-		ctx.Done()
+// checkoutErrorCode is the status code a failed checkout responds with.
+func checkoutErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCartChanged), errors.Is(err, database.ErrOutOfStock),
+		errors.Is(err, database.ErrStoreCreditChanged):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrCartIsEmpty), errors.Is(err, database.ErrAddressNotFound),
+		errors.Is(err, database.ErrAddressRequired), errors.Is(err, database.ErrConflictingAddress),
+		errors.Is(err, database.ErrIncompleteAddress),
+		errors.Is(err, database.ErrInvalidPaymentMethod), errors.Is(err, database.ErrInvalidQuantity),
+		errors.Is(err, database.ErrTooManyUnits), errors.Is(err, pricing.ErrAmountTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCouponNotFound), errors.Is(err, database.ErrCouponNotStarted),
+		errors.Is(err, database.ErrCouponExpired), errors.Is(err, database.ErrCouponUsedUp),
+		errors.Is(err, database.ErrCouponUserLimit), errors.Is(err, pricing.ErrCouponMinSpend),
+		errors.Is(err, pricing.ErrCouponNotApplicable), errors.Is(err, database.ErrCouponNotCombinable),
+		errors.Is(err, pricing.ErrNoShippingZone), errors.Is(err, pricing.ErrDeliveryUnavailable),
+		errors.Is(err, divisions.ErrUnknownProvince), errors.Is(err, divisions.ErrDistrictNotInProvince),
+		errors.Is(err, divisions.ErrWardNotInDistrict), errors.Is(err, divisions.ErrDivisionsNotListed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, database.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
 	}
+	return http.StatusInternalServerError
 }
 
 func (app *Application) BuyFromCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
-		userQueryId := c.Query("userId")
-		if userQueryId == "" {
-			log.Panicln("user id is empty")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("UserID is empty"))
+		fingerprint := c.Query("cartFingerprint")
+		var opts models.CheckoutOptions
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&opts); err != nil {
+				response.Status = "Failed"
+				response.Code = http.StatusBadRequest
+				response.Msg = err.Error()
+				c.IndentedJSON(http.StatusBadRequest, response)
+				return
+			}
+		}
+		if validationErr := Validate.Struct(opts); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
 		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		err := database.BuyItemFromCart(ctx, app.userCollection, userQueryId, app.orderCollection)
+		order, review, err := database.BuyItemFromCart(ctx, app.productCollection, app.userCollection, c.GetString("uid"), app.orderCollection, app.paymentCollection, app.counterCollection, app.pricer, fingerprint, opts)
+		if errors.Is(err, database.ErrCartChanged) {
+			response.Status = "Failed"
+			response.Code = http.StatusConflict
+			response.Msg = "Some items in the cart have changed. Review the changes and check out again with cartFingerprint set to the fingerprint returned"
+			response.Data = review
+			c.IndentedJSON(http.StatusConflict, response)
+			return
+		}
 		if err != nil {
+			code := checkoutErrorCode(err)
 			response.Status = "Failed"
-			response.Code = http.StatusInternalServerError
+			response.Code = uint(code)
 			response.Msg = err.Error()
-			c.IndentedJSON(http.StatusInternalServerError, response)
+			c.IndentedJSON(code, response)
 			return
 		}
 
 		response.Status = "OK"
 		response.Code = 200
 		response.Msg = "Successfully placed the order"
+		response.Data = order
 		c.IndentedJSON(200, response)
 		return
 	}
 }
 
-// func (app *Application) InstantBuy() gin.HandlerFunc {
-// 	return func(c *gin.Context) {
-// 		var response models.Response
-// 		userQueryId := c.Query("userId")
-// 		if userQueryId == "" {
-// 			log.Println("UserID is empty")
-// 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("UserID is empty"))
-// 		}
-// 		productQueryId := c.Query("productId")
-// 		if productQueryId == "" {
-// 			log.Println("Product_ID id is empty")
-// 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("product_id is empty"))
-// 		}
-// 		productId, err := primitive.ObjectIDFromHex(productQueryId)
-// 		if err != nil {
-// 			log.Println(err)
-// 			c.AbortWithStatus(http.StatusInternalServerError)
-// 			return
-// 		}
-
-// 		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-// 		defer cancel()
-// 		err = database.InstantBuyer(ctx, app.productCollection, app.userCollection, productId, userQueryId)
-// 		if err != nil {
-// 			response.Status = "Failed"
-// 			response.Code = http.StatusInternalServerError
-// 			response.Msg = err.Error()
-// 			c.IndentedJSON(http.StatusInternalServerError, response)
-// 			return
-// 		}
-
-// 		response.Status = "OK"
-// 		response.Code = 200
-// 		response.Msg = "Successfully placed the order"
-// 		c.IndentedJSON(200, response)
-// 		return
-// 	}
-// }
+func (app *Application) InstantBuy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.InstantBuyRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		productId, err := primitive.ObjectIDFromHex(request.ProductId)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Invalid product id"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if request.Quantity == 0 {
+			request.Quantity = 1
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+		order, err := database.InstantBuyer(ctx, app.productCollection, app.userCollection, app.orderCollection, app.paymentCollection, app.counterCollection, app.pricer, productId, request.Quantity, c.GetString("uid"), request.CheckoutOptions)
+		if err != nil {
+			code := checkoutErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully placed the order"
+		response.Data = order
+		c.IndentedJSON(200, response)
+		return
+	}
+}
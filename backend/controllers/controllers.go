@@ -23,6 +23,17 @@ import (
 var UserCollection *mongo.Collection = database.UserData(database.Client, "Users")
 var ProductCollection *mongo.Collection = database.ProductData(database.Client, "Products")
 var OrderCollection *mongo.Collection = database.OrderData(database.Client, "Orders")
+var GuestCartCollection *mongo.Collection = database.GuestCartData(database.Client, "GuestCarts")
+var ReturnCollection *mongo.Collection = database.ReturnData(database.Client, "Returns")
+var PaymentCollection *mongo.Collection = database.PaymentData(database.Client, "PaymentIntents")
+var CouponCollection *mongo.Collection = database.CouponData(database.Client, "Coupons")
+var PromotionCollection *mongo.Collection = database.PromotionData(database.Client, "Promotions")
+var WebhookEventCollection *mongo.Collection = database.WebhookEventData(database.Client, "WebhookEvents")
+var TaxRateCollection *mongo.Collection = database.TaxRateData(database.Client, "TaxRates")
+var ShipmentCollection *mongo.Collection = database.ShipmentData(database.Client, "Shipments")
+var ShippingZoneCollection *mongo.Collection = database.ShippingZoneData(database.Client, "ShippingZones")
+var ExportJobCollection *mongo.Collection = database.ExportJobData(database.Client, "ExportJobs")
+var Pricer = database.NewCartPricer(CouponCollection, PromotionCollection, TaxRateCollection, ShippingZoneCollection, OrderCollection)
 var Validate = validator.New()
 
 func HashPassword(password string) string {
@@ -91,12 +102,16 @@ func SignUp() gin.HandlerFunc {
 		user.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.Id = primitive.NewObjectID()
 		user.UserId = user.Id.Hex()
-		token, refreshToken, _ := generate.TokenGenerator(user.Phone, user.FirstName, user.LastName, user.UserId)
+		token, refreshToken, _ := generate.TokenGenerator(user.Phone, user.FirstName, user.LastName, user.UserId, user.Role)
 		user.Token = token
 		user.RefreshToken = refreshToken
 		user.UserCart = make([]models.Product, 0)
 		user.AddressDetails = make([]models.Address, 0)
-		user.Orders = make([]models.Order, 0)
+		// Balances and defaults are earned or set later, never at sign up.
+		user.StoreCredit = 0
+		user.AppliedCoupon = ""
+		user.DefaultShippingAddress = primitive.NilObjectID
+		user.DefaultBillingAddress = primitive.NilObjectID
 		_, inserterr := UserCollection.InsertOne(ctx, user)
 		if inserterr != nil {
 			response.Status = "Failed"
@@ -105,6 +120,7 @@ func SignUp() gin.HandlerFunc {
 			c.IndentedJSON(http.StatusInternalServerError, response)
 			return
 		}
+		mergeGuestCart(ctx, c, user.UserId)
 
 		response.Status = "OK"
 		response.Code = http.StatusCreated
@@ -147,7 +163,7 @@ func LogIn() gin.HandlerFunc {
 			fmt.Println(msg)
 			return
 		}
-		token, refreshToken, _ := generate.TokenGenerator(founduser.Phone, founduser.FirstName, founduser.LastName, founduser.UserId)
+		token, refreshToken, _ := generate.TokenGenerator(founduser.Phone, founduser.FirstName, founduser.LastName, founduser.UserId, founduser.Role)
 
 		updateErr := generate.UpdateAllTokens(token, refreshToken, founduser.UserId)
 		if updateErr != nil {
@@ -157,6 +173,7 @@ func LogIn() gin.HandlerFunc {
 			c.IndentedJSON(http.StatusInternalServerError, response)
 			return
 		}
+		mergeGuestCart(ctx, c, founduser.UserId)
 
 		var usert models.User
 		UserCollection.FindOne(ctx, bson.M{"phone": user.Phone}).Decode(&usert)
@@ -270,41 +287,56 @@ func SearchProductByQuery() gin.HandlerFunc {
 	}
 }
 
+// GetAllOrders lists the orders staff ask for, a page at a time. See
+// adminOrderFilter for the filters.
 func GetAllOrders() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var response models.Response
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
-
-		var orderList []models.Order
-		cursor, err := OrderCollection.Find(ctx, bson.D{{}})
+		page, pageSize, err := pagination(c)
 		if err != nil {
 			response.Status = "Failed"
-			response.Code = http.StatusInternalServerError
-			response.Msg = "Something went wrong. Please try again later"
-			c.IndentedJSON(http.StatusInternalServerError, response)
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
 			return
 		}
-		err = cursor.All(ctx, &orderList)
+		filter, err := adminOrderFilter(c)
 		if err != nil {
-			log.Println(err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
 			return
 		}
-		defer cursor.Close(ctx)
-		if err := cursor.Err(); err != nil {
-			log.Println(err)
+		sort, err := database.OrderSort(c.Query("sort"))
+		if err != nil {
 			response.Status = "Failed"
-			response.Code = 400
-			response.Msg = "Invalid"
-			c.IndentedJSON(400, response)
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orders, total, err := database.ListOrders(ctx, OrderCollection, UserCollection, filter, sort, page, pageSize)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
 			return
 		}
 
 		response.Status = "OK"
 		response.Code = 200
 		response.Msg = "Successfully"
-		response.Data = orderList
+		response.Data = gin.H{"orders": orders,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		}
 		c.IndentedJSON(200, response)
 		return
 	}
@@ -387,11 +419,41 @@ func ProductUpdaterAdmin() gin.HandlerFunc {
 			return
 		}
 
+		fields := bson.D{primitive.E{Key: "product_name", Value: name}, {Key: "price", Value: price}}
+		if stockString := c.PostForm("stock"); stockString != "" {
+			stock, err := strconv.ParseInt(stockString, 10, 64)
+			if err != nil || stock < 0 {
+				response.Status = "Failed"
+				response.Code = http.StatusBadRequest
+				response.Msg = "Invalid stock"
+				c.IndentedJSON(http.StatusBadRequest, response)
+				return
+			}
+			fields = append(fields, primitive.E{Key: "stock", Value: stock})
+		}
+		if category := c.PostForm("category"); category != "" {
+			fields = append(fields, primitive.E{Key: "category", Value: category})
+		}
+		if taxClass := c.PostForm("tax_class"); taxClass != "" {
+			fields = append(fields, primitive.E{Key: "tax_class", Value: taxClass})
+		}
+		if weightString := c.PostForm("weight"); weightString != "" {
+			weight, err := strconv.ParseUint(weightString, 10, 64)
+			if err != nil {
+				response.Status = "Failed"
+				response.Code = http.StatusBadRequest
+				response.Msg = "Invalid weight"
+				c.IndentedJSON(http.StatusBadRequest, response)
+				return
+			}
+			fields = append(fields, primitive.E{Key: "weight", Value: weight})
+		}
+
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		filter := bson.D{primitive.E{Key: "_id", Value: productId}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "product_name", Value: name}, {Key: "price", Value: price}}}}
+		update := bson.D{{Key: "$set", Value: fields}}
 		_, err = ProductCollection.UpdateOne(ctx, filter, update)
 		if err != nil {
 			response.Status = "Failed"
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/pricing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func couponErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCouponNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrCouponCodeTaken):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidCouponValue), errors.Is(err, database.ErrUserIdIsNotValid):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCouponNotStarted), errors.Is(err, database.ErrCouponExpired),
+		errors.Is(err, database.ErrCouponUsedUp), errors.Is(err, database.ErrCouponUserLimit),
+		errors.Is(err, pricing.ErrCouponMinSpend), errors.Is(err, pricing.ErrCouponNotApplicable),
+		errors.Is(err, database.ErrCouponNotCombinable), errors.Is(err, database.ErrCartIsEmpty):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// saveCouponHandler binds and validates a coupon request and hands it to save.
+func saveCouponHandler(code int, msg string, save func(ctx context.Context, c *gin.Context, request models.CouponRequest) (models.Coupon, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.CouponRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		coupon, err := save(ctx, c, request)
+		if err != nil {
+			code := couponErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = uint(code)
+		response.Msg = msg
+		response.Data = coupon
+		c.IndentedJSON(code, response)
+		return
+	}
+}
+
+func CreateCoupon() gin.HandlerFunc {
+	return saveCouponHandler(http.StatusCreated, "Successfully created the coupon", func(ctx context.Context, c *gin.Context, request models.CouponRequest) (models.Coupon, error) {
+		return database.CreateCoupon(ctx, CouponCollection, request)
+	})
+}
+
+func UpdateCoupon() gin.HandlerFunc {
+	return saveCouponHandler(http.StatusOK, "Successfully updated the coupon", func(ctx context.Context, c *gin.Context, request models.CouponRequest) (models.Coupon, error) {
+		couponId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return models.Coupon{}, database.ErrCouponNotFound
+		}
+		return database.UpdateCoupon(ctx, CouponCollection, couponId, request)
+	})
+}
+
+func GetCoupons() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		coupons, err := database.ListCoupons(ctx, CouponCollection)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = coupons
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// ApplyCoupon applies a coupon code to the user's cart and returns the cart's
+// price breakdown with the discount.
+func ApplyCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.ApplyCouponRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		breakdown, err := database.ApplyCoupon(ctx, ProductCollection, UserCollection, Pricer, c.GetString("uid"), request.Code)
+		if err != nil {
+			code := couponErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully applied the coupon"
+		response.Data = breakdown
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func RemoveCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := database.RemoveCoupon(ctx, UserCollection, c.GetString("uid")); err != nil {
+			code := couponErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully removed the coupon"
+		c.IndentedJSON(200, response)
+		return
+	}
+}
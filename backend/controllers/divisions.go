@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"net/http"
+
+	"backend/divisions"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProvinces and the handlers below list the divisions of Vietnam for the
+// cascading city, district and ward pickers of the address form.
+func GetProvinces() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = divisions.Provinces()
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func GetDistricts() gin.HandlerFunc {
+	return divisionListHandler(divisions.Districts)
+}
+
+func GetWards() gin.HandlerFunc {
+	return divisionListHandler(divisions.Wards)
+}
+
+func divisionListHandler(list func(code string) ([]divisions.Division, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		children, err := list(c.Param("code"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = children
+		c.IndentedJSON(200, response)
+		return
+	}
+}
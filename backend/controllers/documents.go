@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/documents"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// documentHandler builds the routes that download a PDF document of the order
+// or shipment whose id is in the path. render returns the document and the
+// name of the file to save it as.
+func documentHandler(notFound error, render func(ctx context.Context, c *gin.Context, id primitive.ObjectID) ([]byte, string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = notFound.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		document, name, err := render(ctx, c, id)
+		if err != nil {
+			code := shipmentErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+		c.Data(http.StatusOK, "application/pdf", document)
+		return
+	}
+}
+
+func invoice(order models.Order) ([]byte, string, error) {
+	return documents.Invoice(order, database.OrderBreakdown(order)), "invoice-" + documents.OrderReference(order) + ".pdf", nil
+}
+
+func GetOrderInvoice() gin.HandlerFunc {
+	return documentHandler(database.ErrCantFindOrder, func(ctx context.Context, c *gin.Context, orderId primitive.ObjectID) ([]byte, string, error) {
+		order, err := database.GetOrder(ctx, OrderCollection, orderId)
+		if err != nil {
+			return nil, "", err
+		}
+		return invoice(order)
+	})
+}
+
+// GetUserInvoice lets customers download the invoices of their own orders.
+func GetUserInvoice() gin.HandlerFunc {
+	return documentHandler(database.ErrCantFindOrder, func(ctx context.Context, c *gin.Context, orderId primitive.ObjectID) ([]byte, string, error) {
+		order, err := database.GetUserOrder(ctx, OrderCollection, c.GetString("uid"), orderId)
+		if err != nil {
+			return nil, "", err
+		}
+		return invoice(order)
+	})
+}
+
+func GetOrderPackingSlip() gin.HandlerFunc {
+	return documentHandler(database.ErrCantFindOrder, func(ctx context.Context, c *gin.Context, orderId primitive.ObjectID) ([]byte, string, error) {
+		order, err := database.GetOrder(ctx, OrderCollection, orderId)
+		if err != nil {
+			return nil, "", err
+		}
+		return documents.PackingSlip(order, nil), "packing-slip-" + documents.OrderReference(order) + ".pdf", nil
+	})
+}
+
+// GetShipmentPackingSlip is the packing slip of one package of a split order.
+func GetShipmentPackingSlip() gin.HandlerFunc {
+	return documentHandler(database.ErrCantFindShipment, func(ctx context.Context, c *gin.Context, shipmentId primitive.ObjectID) ([]byte, string, error) {
+		shipment, err := database.GetShipment(ctx, ShipmentCollection, shipmentId)
+		if err != nil {
+			return nil, "", err
+		}
+		order, err := database.GetOrder(ctx, OrderCollection, shipment.OrderId)
+		if err != nil {
+			return nil, "", err
+		}
+		return documents.PackingSlip(order, shipment.Lines), "packing-slip-" + documents.OrderReference(order) + "-" + shipmentId.Hex() + ".pdf", nil
+	})
+}
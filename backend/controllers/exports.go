@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/export"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportJobListLimit is how many of the latest export jobs are listed.
+const exportJobListLimit = 50
+
+// exportErrorCode is the status code a failed export responds with.
+func exportErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrInvalidExportRange), errors.Is(err, export.ErrUnknownFormat),
+		errors.Is(err, export.ErrUnknownColumn):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCantFindExport), errors.Is(err, export.ErrUnknownDataset):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrExportNotReady):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// exportRequest reads an export of the dataset in the path from the format
+// (csv unless xlsx), from, to and columns query parameters. Columns are
+// separated by commas.
+func exportRequest(c *gin.Context) (models.ExportRequest, error) {
+	request := models.ExportRequest{Dataset: c.Param("dataset"), Format: c.DefaultQuery("format", export.FormatCSV)}
+	if columns := c.Query("columns"); columns != "" {
+		request.Columns = strings.Split(columns, ",")
+	}
+	var err error
+	if request.From, err = queryTime(c.Query("from"), false); err != nil {
+		return request, database.ErrInvalidExportRange
+	}
+	if request.To, err = queryTime(c.Query("to"), true); err != nil {
+		return request, database.ErrInvalidExportRange
+	}
+	return request, database.CheckExport(request)
+}
+
+// ExportOrders streams a spreadsheet of the orders or order lines placed in a
+// date range. Ranges of more than database.ExportStreamLimit orders, or any
+// range when background=true, are written as a job to download later instead.
+func ExportOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		request, err := exportRequest(c)
+		if err != nil {
+			code := exportErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		count, err := database.CountExport(ctx, OrderCollection, request)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+		if count > database.ExportStreamLimit || c.Query("background") == "true" {
+			job, err := database.StartExport(OrderCollection, ExportJobCollection, request)
+			if err != nil {
+				code := exportErrorCode(err)
+				response.Status = "Failed"
+				response.Code = uint(code)
+				response.Msg = err.Error()
+				c.IndentedJSON(code, response)
+				return
+			}
+			response.Status = "OK"
+			response.Code = http.StatusAccepted
+			response.Msg = "Export started"
+			response.Data = job
+			c.IndentedJSON(http.StatusAccepted, response)
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="`+database.ExportFileName(request)+`"`)
+		c.Header("Content-Type", export.ContentType(request.Format))
+		c.Status(http.StatusOK)
+		if _, err := database.WriteExport(ctx, OrderCollection, request, c.Writer); err != nil {
+			// The rows already sent cannot be taken back; the file is left cut short.
+			log.Println(err)
+		}
+		return
+	}
+}
+
+// GetExportColumns lists the columns an export of the dataset can have.
+func GetExportColumns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		columns, err := export.ColumnNames(c.Param("dataset"))
+		if err != nil {
+			code := exportErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = columns
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func GetExportJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		jobs, err := database.ListExportJobs(ctx, ExportJobCollection, exportJobListLimit)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = jobs
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// exportJobHandler builds the routes that act on the export job whose id is in
+// the path.
+func exportJobHandler(handle func(ctx context.Context, c *gin.Context, jobId primitive.ObjectID) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		jobId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindExport.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err = handle(ctx, c, jobId); err != nil {
+			code := exportErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+		return
+	}
+}
+
+func GetExportJob() gin.HandlerFunc {
+	return exportJobHandler(func(ctx context.Context, c *gin.Context, jobId primitive.ObjectID) error {
+		job, err := database.GetExportJob(ctx, ExportJobCollection, jobId)
+		if err != nil {
+			return err
+		}
+		c.IndentedJSON(200, models.Response{Status: "OK", Code: 200, Msg: "Successfully", Data: job})
+		return nil
+	})
+}
+
+// DownloadExportJob sends the file of a ready export job.
+func DownloadExportJob() gin.HandlerFunc {
+	return exportJobHandler(func(ctx context.Context, c *gin.Context, jobId primitive.ObjectID) error {
+		job, err := database.GetExportJob(ctx, ExportJobCollection, jobId)
+		if err != nil {
+			return err
+		}
+		file, err := database.OpenExport(ExportJobCollection, job)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		c.Header("Content-Disposition", `attachment; filename="`+database.ExportFileName(job.ExportRequest)+`"`)
+		c.DataFromReader(http.StatusOK, file.GetFile().Length, export.ContentType(job.Format), file, nil)
+		return nil
+	})
+}
+
+func DeleteExportJob() gin.HandlerFunc {
+	return exportJobHandler(func(ctx context.Context, c *gin.Context, jobId primitive.ObjectID) error {
+		if err := database.DeleteExportJob(ctx, ExportJobCollection, jobId); err != nil {
+			return err
+		}
+		c.IndentedJSON(200, models.Response{Status: "OK", Code: 200, Msg: "Successfully deleted the export"})
+		return nil
+	})
+}
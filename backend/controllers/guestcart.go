@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	generate "backend/tokens"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const cartTokenCookie = "cart_token"
+
+// guestCartId returns the id of the guest cart named by the signed cart token,
+// read from the cart cookie or the cart-token header.
+func guestCartId(c *gin.Context) string {
+	cartToken, err := c.Cookie(cartTokenCookie)
+	if err != nil || cartToken == "" {
+		cartToken = c.Request.Header.Get("cart-token")
+	}
+	if cartToken == "" {
+		return ""
+	}
+	cartId, msg := generate.ValidateCartToken(cartToken)
+	if msg != "" {
+		log.Println(msg)
+		return ""
+	}
+	return cartId
+}
+
+func setCartToken(c *gin.Context, cartId string) (string, error) {
+	cartToken, err := generate.CartTokenGenerator(cartId)
+	if err != nil {
+		return "", err
+	}
+	c.SetCookie(cartTokenCookie, cartToken, int(database.GuestCartTTL.Seconds()), "/", "", false, true)
+	return cartToken, nil
+}
+
+// mergeGuestCart moves the caller's guest cart, if any, into the user's cart.
+// A failed merge does not fail the sign up or log in it is part of.
+func mergeGuestCart(ctx context.Context, c *gin.Context, userId string) {
+	cartId := guestCartId(c)
+	if cartId == "" {
+		return
+	}
+	if err := database.MergeGuestCart(ctx, GuestCartCollection, UserCollection, cartId, userId); err != nil {
+		log.Println(err)
+		return
+	}
+	c.SetCookie(cartTokenCookie, "", -1, "/", "", false, true)
+}
+
+func AddToGuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		productQueryId := c.Query("productId")
+		if productQueryId == "" {
+			log.Println("product id is empty")
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("product id is empty"))
+			return
+		}
+		productId, err := primitive.ObjectIDFromHex(productQueryId)
+		if err != nil {
+			log.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		quantity, err := cartQuantity(c)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Invalid quantity"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cartId := guestCartId(c)
+		if cartId != "" {
+			if _, err := database.GetGuestCart(ctx, GuestCartCollection, cartId); err != nil {
+				cartId = ""
+			}
+		}
+		if cartId == "" {
+			cart, err := database.CreateGuestCart(ctx, GuestCartCollection)
+			if err != nil {
+				response.Status = "Failed"
+				response.Code = http.StatusInternalServerError
+				response.Msg = err.Error()
+				c.IndentedJSON(http.StatusInternalServerError, response)
+				return
+			}
+			cartId = cart.CartId.Hex()
+		}
+
+		err = database.AddProductToGuestCart(ctx, ProductCollection, GuestCartCollection, productId, cartId, quantity)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+		cartToken, err := setCartToken(c, cartId)
+		if err != nil {
+			log.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully added to the cart"
+		response.Data = gin.H{"cart_token": cartToken}
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func RemoveFromGuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		productQueryId := c.Query("productId")
+		if productQueryId == "" {
+			log.Println("Missing product id")
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Missing product id"))
+			return
+		}
+		productId, err := primitive.ObjectIDFromHex(productQueryId)
+		if err != nil {
+			log.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		cartId := guestCartId(c)
+		if cartId == "" {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = "Missing or invalid cart token"
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = database.RemoveGuestCartItem(ctx, GuestCartCollection, productId, cartId)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully removed from cart"
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func GetItemsFromGuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		cartId := guestCartId(c)
+		if cartId == "" {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = "Missing or invalid cart token"
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		guestCart, err := database.GetGuestCart(ctx, GuestCartCollection, cartId)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		cart, changes, err := database.ResolveCart(ctx, ProductCollection, guestCart.Items)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+		// Guests have no address yet, so only the tax rates and shipping zone
+		// that apply everywhere are used.
+		priced, err := Pricer.PriceCart(ctx, database.PriceRequest{DeliveryOption: c.Query("deliveryOption")}, cart)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		data := gin.H{"items": cart, "changes": changes, "pricing": priced.Breakdown}
+		if priced.DeliveryErr != nil {
+			data["delivery_error"] = priced.DeliveryErr.Error()
+		}
+		response.Data = data
+		c.IndentedJSON(200, response)
+		return
+	}
+}
@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// orderErrorCode is the status code a failed order update responds with.
+func orderErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindOrder):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrIllegalTransition), errors.Is(err, database.ErrOrderNotCancellable), errors.Is(err, database.ErrOrderHasShipments):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidOrderStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCantUpdatePayment):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func UpdateOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderQueryId := c.Query("orderId")
+		if orderQueryId == "" {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Missing order id"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		orderId, err := primitive.ObjectIDFromHex(orderQueryId)
+		if err != nil {
+			log.Println(err)
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Invalid order id"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		var request models.OrderStatusRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, err := database.TransitionOrder(ctx, ProductCollection, OrderCollection, PaymentCollection, orderId, request.Status, request.Note)
+		if err != nil {
+			code := orderErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully updated the order status"
+		response.Data = order
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pagination reads the page and pageSize query parameters of list routes.
+func pagination(c *gin.Context) (int64, int64, error) {
+	page, pageSize := int64(1), int64(defaultPageSize)
+	var err error
+	if pageString := c.Query("page"); pageString != "" {
+		page, err = strconv.ParseInt(pageString, 10, 64)
+		if err != nil || page < 1 {
+			return 0, 0, errors.New("invalid page")
+		}
+	}
+	if pageSizeString := c.Query("pageSize"); pageSizeString != "" {
+		pageSize, err = strconv.ParseInt(pageSizeString, 10, 64)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return 0, 0, errors.New("invalid page size")
+		}
+	}
+	return page, pageSize, nil
+}
+
+func GetUserOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		page, pageSize, err := pagination(c)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		status := models.OrderStatus(c.Query("status"))
+		if status != "" && !status.Valid() {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = database.ErrInvalidOrderStatus.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orders, total, err := database.ListUserOrders(ctx, OrderCollection, c.GetString("uid"), status, c.Query("order_number"), page, pageSize)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = gin.H{"orders": orders,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		}
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func GetUserOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindOrder.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, err := database.GetUserOrder(ctx, OrderCollection, c.GetString("uid"), orderId)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = order
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func CancelUserOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindOrder.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		var request models.CancelOrderRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, err := database.CancelOrder(ctx, ProductCollection, OrderCollection, PaymentCollection, ShipmentCollection, c.GetString("uid"), orderId, request.Reason)
+		if err != nil {
+			code := orderErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully cancelled the order"
+		response.Data = order
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// adminOrderFilter reads the filters of the admin order list: status,
+// order_number, from and to (dates or RFC 3339 times, to including the whole
+// day when it is a date), phone, min_total, max_total and payment_method.
+func adminOrderFilter(c *gin.Context) (database.OrderFilter, error) {
+	filter := database.OrderFilter{
+		Status:        models.OrderStatus(c.Query("status")),
+		OrderNumber:   c.Query("order_number"),
+		Phone:         c.Query("phone"),
+		PaymentMethod: c.Query("payment_method"),
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return filter, database.ErrInvalidOrderStatus
+	}
+	var err error
+	if filter.From, err = queryTime(c.Query("from"), false); err != nil {
+		return filter, errors.New("invalid from")
+	}
+	if filter.To, err = queryTime(c.Query("to"), true); err != nil {
+		return filter, errors.New("invalid to")
+	}
+	if minTotal := c.Query("min_total"); minTotal != "" {
+		if filter.MinTotal, err = strconv.ParseUint(minTotal, 10, 64); err != nil {
+			return filter, errors.New("invalid min_total")
+		}
+	}
+	if maxTotal := c.Query("max_total"); maxTotal != "" {
+		if filter.MaxTotal, err = strconv.ParseUint(maxTotal, 10, 64); err != nil {
+			return filter, errors.New("invalid max_total")
+		}
+	}
+	return filter, nil
+}
+
+// queryTime reads a time given as RFC 3339 or as a date. A date that ends a
+// range stands for the start of the next day, so that the day is included.
+func queryTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// GetOrder shows staff one order with the customer who placed it.
+func GetOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Invalid order id"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, err := database.GetAdminOrder(ctx, OrderCollection, UserCollection, orderId)
+		if err != nil {
+			code := orderErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = order
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// BulkUpdateOrderStatus moves many orders to one status. Orders that cannot
+// move are reported in the results and do not stop the others.
+func BulkUpdateOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.BulkOrderStatusRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		results, err := database.TransitionOrders(ctx, ProductCollection, OrderCollection, PaymentCollection, request.OrderIds, request.Status, request.Note)
+		if err != nil {
+			code := orderErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+		failed := 0
+		for _, result := range results {
+			if result.Error != "" {
+				failed++
+			}
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully updated the order statuses"
+		response.Data = gin.H{"results": results,
+			"updated": len(results) - failed,
+			"failed":  failed,
+		}
+		c.IndentedJSON(200, response)
+		return
+	}
+}
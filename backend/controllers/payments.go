@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPaymentMethods lists the payment methods the customer can choose at
+// checkout.
+func GetPaymentMethods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		response.Status = "OK"
+		response.Code = http.StatusOK
+		response.Msg = "Successfully listed the payment methods"
+		response.Data = payment.Methods()
+		c.IndentedJSON(http.StatusOK, response)
+		return
+	}
+}
+
+func webhookErrorCode(err error) int {
+	switch {
+	case errors.Is(err, payment.ErrInvalidSignature), errors.Is(err, payment.ErrStaleWebhook):
+		return http.StatusUnauthorized
+	case errors.Is(err, payment.ErrInvalidWebhookPayload), errors.Is(err, payment.ErrWebhookNotSupported):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCantFindPayment):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// PaymentWebhook receives payment events from the provider named in the path.
+// The signature in the Webhook-Signature header is checked against the raw
+// body before anything is read from it. Redelivered events are acknowledged
+// without being applied again, so that the provider stops sending them.
+func PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		provider, err := payment.Get(c.Param("provider"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = payment.ErrInvalidWebhookPayload.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		event, err := provider.VerifyWebhook(payload, c.GetHeader("Webhook-Signature"))
+		if err != nil {
+			code := webhookErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		intent, err := database.ProcessPaymentEvent(ctx, ProductCollection, OrderCollection, PaymentCollection, WebhookEventCollection, event)
+		if errors.Is(err, database.ErrDuplicateWebhookEvent) {
+			response.Status = "OK"
+			response.Code = http.StatusOK
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusOK, response)
+			return
+		}
+		if err != nil {
+			code := webhookErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = http.StatusOK
+		response.Msg = "Successfully processed the event"
+		response.Data = intent
+		c.IndentedJSON(http.StatusOK, response)
+		return
+	}
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func promotionErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindPromotion):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrInvalidPromotion):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// savePromotionHandler binds and validates a promotion request and hands it to
+// save.
+func savePromotionHandler(code int, msg string, save func(ctx context.Context, c *gin.Context, request models.PromotionRequest) (models.Promotion, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.PromotionRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		promotion, err := save(ctx, c, request)
+		if err != nil {
+			code := promotionErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = uint(code)
+		response.Msg = msg
+		response.Data = promotion
+		c.IndentedJSON(code, response)
+		return
+	}
+}
+
+func CreatePromotion() gin.HandlerFunc {
+	return savePromotionHandler(http.StatusCreated, "Successfully created the promotion", func(ctx context.Context, c *gin.Context, request models.PromotionRequest) (models.Promotion, error) {
+		return database.CreatePromotion(ctx, PromotionCollection, request)
+	})
+}
+
+func UpdatePromotion() gin.HandlerFunc {
+	return savePromotionHandler(http.StatusOK, "Successfully updated the promotion", func(ctx context.Context, c *gin.Context, request models.PromotionRequest) (models.Promotion, error) {
+		promotionId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return models.Promotion{}, database.ErrCantFindPromotion
+		}
+		return database.UpdatePromotion(ctx, PromotionCollection, promotionId, request)
+	})
+}
+
+func GetPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		promotions, err := database.ListPromotions(ctx, PromotionCollection)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = promotions
+		c.IndentedJSON(200, response)
+		return
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// returnErrorCode is the status code a failed return request or decision
+// responds with.
+func returnErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindOrder), errors.Is(err, database.ErrCantFindReturn):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrOrderNotReturnable), errors.Is(err, database.ErrReturnWindowClosed),
+		errors.Is(err, database.ErrIllegalReturnDecision), errors.Is(err, database.ErrIllegalTransition):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidReturnQuantity):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCantUpdatePayment):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func RequestReturn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindOrder.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		var request models.ReturnRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderReturn, err := database.RequestReturn(ctx, OrderCollection, ReturnCollection, c.GetString("uid"), orderId, request)
+		if err != nil {
+			code := returnErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = http.StatusCreated
+		response.Msg = "Successfully requested the return"
+		response.Data = orderReturn
+		c.IndentedJSON(http.StatusCreated, response)
+		return
+	}
+}
+
+func GetUserReturns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		returns, err := database.ListReturns(ctx, ReturnCollection, bson.D{primitive.E{Key: "user_id", Value: c.GetString("uid")}})
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = returns
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func GetAllReturns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		filter := bson.D{}
+		if status := c.Query("status"); status != "" {
+			filter = append(filter, primitive.E{Key: "status", Value: status})
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		returns, err := database.ListReturns(ctx, ReturnCollection, filter)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = returns
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// decideReturnHandler builds the admin routes that move a return along; decide
+// performs the move for the return id taken from the path.
+func decideReturnHandler(msg string, decide func(ctx context.Context, returnId primitive.ObjectID, note string) (models.Return, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		returnId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindReturn.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		var decision models.ReturnDecision
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&decision); err != nil {
+				response.Status = "Failed"
+				response.Code = http.StatusBadRequest
+				response.Msg = err.Error()
+				c.IndentedJSON(http.StatusBadRequest, response)
+				return
+			}
+		}
+		if validationErr := Validate.Struct(decision); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderReturn, err := decide(ctx, returnId, decision.Note)
+		if err != nil {
+			code := returnErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = msg
+		response.Data = orderReturn
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func ReceiveReturn() gin.HandlerFunc {
+	return decideReturnHandler("Successfully recorded the returned goods", func(ctx context.Context, returnId primitive.ObjectID, note string) (models.Return, error) {
+		return database.ReceiveReturn(ctx, ReturnCollection, returnId, note)
+	})
+}
+
+func ApproveReturn() gin.HandlerFunc {
+	return decideReturnHandler("Successfully approved the return", func(ctx context.Context, returnId primitive.ObjectID, note string) (models.Return, error) {
+		return database.ApproveReturn(ctx, ProductCollection, OrderCollection, PaymentCollection, UserCollection, ReturnCollection, returnId, note)
+	})
+}
+
+func RejectReturn() gin.HandlerFunc {
+	return decideReturnHandler("Successfully rejected the return", func(ctx context.Context, returnId primitive.ObjectID, note string) (models.Return, error) {
+		return database.RejectReturn(ctx, OrderCollection, ReturnCollection, returnId, note)
+	})
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/carrier"
+	"backend/database"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shipmentErrorCode is the status code a failed shipment update responds with.
+func shipmentErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindOrder), errors.Is(err, database.ErrCantFindShipment):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrOrderNotShippable), errors.Is(err, database.ErrNothingToShip),
+		errors.Is(err, database.ErrShipmentClosed), errors.Is(err, database.ErrIllegalTransition),
+		errors.Is(err, database.ErrShipmentNotCancellable):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidShipmentQuantity), errors.Is(err, carrier.ErrUnknownCarrier):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCantUpdatePayment), errors.Is(err, database.ErrCarrierUnavailable):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func CreateShipment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindOrder.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		var request models.ShipmentRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		shipment, err := database.CreateShipment(ctx, ProductCollection, OrderCollection, PaymentCollection, ShipmentCollection, orderId, request)
+		if err != nil {
+			code := shipmentErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = http.StatusCreated
+		response.Msg = "Successfully created the shipment"
+		response.Data = shipment
+		c.IndentedJSON(http.StatusCreated, response)
+		return
+	}
+}
+
+func RecordTrackingEvent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		shipmentId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindShipment.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		var request models.TrackingEventRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		shipment, err := database.RecordTrackingEvent(ctx, ProductCollection, OrderCollection, PaymentCollection, ShipmentCollection, shipmentId, request)
+		if err != nil {
+			code := shipmentErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully recorded the tracking event"
+		response.Data = shipment
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// listShipmentsHandler builds the routes listing the shipments of the order
+// whose id is in the path.
+func listShipmentsHandler(list func(ctx context.Context, c *gin.Context, orderId primitive.ObjectID) ([]models.Shipment, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		orderId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindOrder.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		shipments, err := list(ctx, c, orderId)
+		if err != nil {
+			code := shipmentErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = shipments
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func GetOrderShipments() gin.HandlerFunc {
+	return listShipmentsHandler(func(ctx context.Context, c *gin.Context, orderId primitive.ObjectID) ([]models.Shipment, error) {
+		return database.ListOrderShipments(ctx, ShipmentCollection, orderId)
+	})
+}
+
+// GetUserShipments lets customers track the shipments of their own orders.
+func GetUserShipments() gin.HandlerFunc {
+	return listShipmentsHandler(func(ctx context.Context, c *gin.Context, orderId primitive.ObjectID) ([]models.Shipment, error) {
+		return database.ListUserShipments(ctx, OrderCollection, ShipmentCollection, c.GetString("uid"), orderId)
+	})
+}
+
+// GetCarriers lists the carriers shipments can be sent with.
+func GetCarriers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = carrier.Names()
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// SyncShipment pulls the latest tracking of the shipment from its carrier.
+func SyncShipment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		shipmentId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindShipment.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		shipment, err := database.SyncShipment(ctx, ProductCollection, OrderCollection, PaymentCollection, ShipmentCollection, shipmentId)
+		if err != nil {
+			code := shipmentErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully synced the shipment tracking"
+		response.Data = shipment
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func CancelShipment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		shipmentId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindShipment.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+		var request models.CancelShipmentRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				response.Status = "Failed"
+				response.Code = http.StatusBadRequest
+				response.Msg = err.Error()
+				c.IndentedJSON(http.StatusBadRequest, response)
+				return
+			}
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		shipment, err := database.CancelShipment(ctx, ShipmentCollection, shipmentId, request.Reason)
+		if err != nil {
+			code := shipmentErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully cancelled the shipment"
+		response.Data = shipment
+		c.IndentedJSON(200, response)
+		return
+	}
+}
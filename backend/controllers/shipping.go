@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/divisions"
+	"backend/models"
+	"backend/pricing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func shippingErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindShippingZone), errors.Is(err, database.ErrAddressNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrUserIdIsNotValid):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCartIsEmpty), errors.Is(err, pricing.ErrNoShippingZone):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, divisions.ErrUnknownProvince), errors.Is(err, divisions.ErrDistrictNotInProvince),
+		errors.Is(err, divisions.ErrDivisionsNotListed), errors.Is(err, divisions.ErrRegionNeedsCity):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// GetShippingQuote lists what each delivery option costs for the user's cart,
+// so that one can be chosen before checkout.
+func GetShippingQuote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		quotes, err := database.QuoteCartShipping(ctx, ProductCollection, UserCollection, Pricer, c.GetString("uid"), c.Query("addressId"))
+		if err != nil {
+			code := shippingErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = quotes
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+// saveShippingZoneHandler binds and validates a shipping zone request and hands
+// it to save.
+func saveShippingZoneHandler(code int, msg string, save func(ctx context.Context, c *gin.Context, request models.ShippingZoneRequest) (models.ShippingZone, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.ShippingZoneRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		zone, err := save(ctx, c, request)
+		if err != nil {
+			code := shippingErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = uint(code)
+		response.Msg = msg
+		response.Data = zone
+		c.IndentedJSON(code, response)
+		return
+	}
+}
+
+func CreateShippingZone() gin.HandlerFunc {
+	return saveShippingZoneHandler(http.StatusCreated, "Successfully created the shipping zone", func(ctx context.Context, c *gin.Context, request models.ShippingZoneRequest) (models.ShippingZone, error) {
+		return database.CreateShippingZone(ctx, ShippingZoneCollection, request)
+	})
+}
+
+func UpdateShippingZone() gin.HandlerFunc {
+	return saveShippingZoneHandler(http.StatusOK, "Successfully updated the shipping zone", func(ctx context.Context, c *gin.Context, request models.ShippingZoneRequest) (models.ShippingZone, error) {
+		zoneId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return models.ShippingZone{}, database.ErrCantFindShippingZone
+		}
+		return database.UpdateShippingZone(ctx, ShippingZoneCollection, zoneId, request)
+	})
+}
+
+func GetShippingZones() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		zones, err := database.ListShippingZones(ctx, ShippingZoneCollection)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = zones
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func DeleteShippingZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		zoneId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindShippingZone.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err = database.DeleteShippingZone(ctx, ShippingZoneCollection, zoneId); err != nil {
+			code := shippingErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully deleted the shipping zone"
+		c.IndentedJSON(200, response)
+		return
+	}
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/divisions"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func taxRateErrorCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindTaxRate):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrTaxRateExists):
+		return http.StatusConflict
+	case errors.Is(err, divisions.ErrUnknownProvince), errors.Is(err, divisions.ErrDistrictNotInProvince),
+		errors.Is(err, divisions.ErrDivisionsNotListed), errors.Is(err, divisions.ErrRegionNeedsCity):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// saveTaxRateHandler binds and validates a tax rate request and hands it to
+// save.
+func saveTaxRateHandler(code int, msg string, save func(ctx context.Context, c *gin.Context, request models.TaxRateRequest) (models.TaxRate, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var request models.TaxRateRequest
+		if err := c.BindJSON(&request); err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+		if validationErr := Validate.Struct(request); validationErr != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = validationErr.Error()
+			c.IndentedJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		rate, err := save(ctx, c, request)
+		if err != nil {
+			code := taxRateErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = uint(code)
+		response.Msg = msg
+		response.Data = rate
+		c.IndentedJSON(code, response)
+		return
+	}
+}
+
+func CreateTaxRate() gin.HandlerFunc {
+	return saveTaxRateHandler(http.StatusCreated, "Successfully created the tax rate", func(ctx context.Context, c *gin.Context, request models.TaxRateRequest) (models.TaxRate, error) {
+		return database.CreateTaxRate(ctx, TaxRateCollection, request)
+	})
+}
+
+func UpdateTaxRate() gin.HandlerFunc {
+	return saveTaxRateHandler(http.StatusOK, "Successfully updated the tax rate", func(ctx context.Context, c *gin.Context, request models.TaxRateRequest) (models.TaxRate, error) {
+		rateId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return models.TaxRate{}, database.ErrCantFindTaxRate
+		}
+		return database.UpdateTaxRate(ctx, TaxRateCollection, rateId, request)
+	})
+}
+
+func GetTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		rates, err := database.ListTaxRates(ctx, TaxRateCollection)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusInternalServerError
+			response.Msg = err.Error()
+			c.IndentedJSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully"
+		response.Data = rates
+		c.IndentedJSON(200, response)
+		return
+	}
+}
+
+func DeleteTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		rateId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusNotFound
+			response.Msg = database.ErrCantFindTaxRate.Error()
+			c.IndentedJSON(http.StatusNotFound, response)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err = database.DeleteTaxRate(ctx, TaxRateCollection, rateId); err != nil {
+			code := taxRateErrorCode(err)
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			return
+		}
+
+		response.Status = "OK"
+		response.Code = 200
+		response.Msg = "Successfully deleted the tax rate"
+		c.IndentedJSON(200, response)
+		return
+	}
+}
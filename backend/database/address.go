@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"backend/divisions"
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrCantUpdateAddress = errors.New("cannot update the address book")
+
+func findAddressBookUser(ctx context.Context, userCollection *mongo.Collection, userId string) (models.User, error) {
+	var user models.User
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return user, ErrUserIdIsNotValid
+	}
+	err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&user)
+	if err != nil {
+		log.Println(err)
+		return user, wrapDriverError(ErrUserIdIsNotValid, err)
+	}
+	return user, nil
+}
+
+func addressBook(user models.User) models.AddressBook {
+	book := models.AddressBook{
+		Addresses:              user.AddressDetails,
+		DefaultShippingAddress: user.DefaultShippingAddress,
+		DefaultBillingAddress:  user.DefaultBillingAddress,
+	}
+	if book.Addresses == nil {
+		book.Addresses = make([]models.Address, 0)
+	}
+	return book
+}
+
+func ListAddresses(ctx context.Context, userCollection *mongo.Collection, userId string) (models.AddressBook, error) {
+	user, err := findAddressBookUser(ctx, userCollection, userId)
+	if err != nil {
+		return models.AddressBook{}, err
+	}
+	return addressBook(user), nil
+}
+
+func trimAddress(address models.Address) models.Address {
+	address.Label = strings.TrimSpace(address.Label)
+	address.RecipientName = strings.TrimSpace(address.RecipientName)
+	address.Phone = strings.TrimSpace(address.Phone)
+	address.House = strings.TrimSpace(address.House)
+	address.Street = strings.TrimSpace(address.Street)
+	address.Ward = strings.TrimSpace(address.Ward)
+	address.District = strings.TrimSpace(address.District)
+	address.City = strings.TrimSpace(address.City)
+	return address
+}
+
+// defaultFields sets the address as the default the request asks for, and as
+// both defaults when the user has none yet.
+func defaultFields(user models.User, addressId primitive.ObjectID, request models.AddressRequest) bson.D {
+	fields := bson.D{}
+	if request.DefaultShipping || user.DefaultShippingAddress.IsZero() {
+		fields = append(fields, primitive.E{Key: "default_shipping_address", Value: addressId})
+	}
+	if request.DefaultBilling || user.DefaultBillingAddress.IsZero() {
+		fields = append(fields, primitive.E{Key: "default_billing_address", Value: addressId})
+	}
+	return fields
+}
+
+func AddAddress(ctx context.Context, userCollection *mongo.Collection, userId string, request models.AddressRequest) (models.AddressBook, error) {
+	user, err := findAddressBookUser(ctx, userCollection, userId)
+	if err != nil {
+		return models.AddressBook{}, err
+	}
+	address, err := divisions.Normalize(trimAddress(request.Address))
+	if err != nil {
+		return models.AddressBook{}, err
+	}
+	address.AddressId = primitive.NewObjectID()
+
+	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "addresses", Value: address}}}}
+	if fields := defaultFields(user, address.AddressId, request); len(fields) > 0 {
+		update = append(update, primitive.E{Key: "$set", Value: fields})
+	}
+	if _, err = userCollection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: user.Id}}, update); err != nil {
+		log.Println(err)
+		return models.AddressBook{}, wrapDriverError(ErrCantUpdateAddress, err)
+	}
+	return ListAddresses(ctx, userCollection, userId)
+}
+
+// UpdateAddress replaces a saved address. Orders already placed keep the copy
+// they were shipped to.
+func UpdateAddress(ctx context.Context, userCollection *mongo.Collection, userId string, addressId primitive.ObjectID, request models.AddressRequest) (models.AddressBook, error) {
+	user, err := findAddressBookUser(ctx, userCollection, userId)
+	if err != nil {
+		return models.AddressBook{}, err
+	}
+	address, err := divisions.Normalize(trimAddress(request.Address))
+	if err != nil {
+		return models.AddressBook{}, err
+	}
+	address.AddressId = addressId
+
+	fields := bson.D{primitive.E{Key: "addresses.$", Value: address}}
+	fields = append(fields, defaultFields(user, addressId, request)...)
+	filter := bson.D{primitive.E{Key: "_id", Value: user.Id}, {Key: "addresses._id", Value: addressId}}
+	result, err := userCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: fields}})
+	if err != nil {
+		log.Println(err)
+		return models.AddressBook{}, wrapDriverError(ErrCantUpdateAddress, err)
+	}
+	if result.MatchedCount == 0 {
+		return models.AddressBook{}, ErrAddressNotFound
+	}
+	return ListAddresses(ctx, userCollection, userId)
+}
+
+// DeleteAddress removes a saved address. A default it was is passed on to the
+// first address left, if there is one.
+func DeleteAddress(ctx context.Context, userCollection *mongo.Collection, userId string, addressId primitive.ObjectID) (models.AddressBook, error) {
+	user, err := findAddressBookUser(ctx, userCollection, userId)
+	if err != nil {
+		return models.AddressBook{}, err
+	}
+	remaining := make([]models.Address, 0, len(user.AddressDetails))
+	for _, address := range user.AddressDetails {
+		if address.AddressId != addressId {
+			remaining = append(remaining, address)
+		}
+	}
+	if len(remaining) == len(user.AddressDetails) {
+		return models.AddressBook{}, ErrAddressNotFound
+	}
+
+	update := bson.D{{Key: "$pull", Value: bson.D{primitive.E{Key: "addresses", Value: bson.D{primitive.E{Key: "_id", Value: addressId}}}}}}
+	set, unset := bson.D{}, bson.D{}
+	for _, key := range []string{"default_shipping_address", "default_billing_address"} {
+		current := user.DefaultShippingAddress
+		if key == "default_billing_address" {
+			current = user.DefaultBillingAddress
+		}
+		if current != addressId {
+			continue
+		}
+		if len(remaining) > 0 {
+			set = append(set, primitive.E{Key: key, Value: remaining[0].AddressId})
+		} else {
+			unset = append(unset, primitive.E{Key: key, Value: ""})
+		}
+	}
+	if len(set) > 0 {
+		update = append(update, primitive.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, primitive.E{Key: "$unset", Value: unset})
+	}
+	if _, err = userCollection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: user.Id}}, update); err != nil {
+		log.Println(err)
+		return models.AddressBook{}, wrapDriverError(ErrCantUpdateAddress, err)
+	}
+	return ListAddresses(ctx, userCollection, userId)
+}
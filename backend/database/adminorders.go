@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidOrderSort = errors.New("orders cannot be sorted this way")
+
+// OrderFilter narrows the orders staff look at. Zero fields do not filter.
+type OrderFilter struct {
+	Status      models.OrderStatus
+	OrderNumber string
+	// From and To bound when the order was placed, From included and To not.
+	From time.Time
+	To   time.Time
+	// Phone matches customers whose phone number contains it.
+	Phone         string
+	MinTotal      uint64
+	MaxTotal      uint64
+	PaymentMethod string
+}
+
+// orderSorts are the fields orders can be sorted by, by the name they are asked
+// for with.
+var orderSorts = map[string]string{
+	"ordered_at":   "ordered_at",
+	"total":        "total_price",
+	"order_number": "order_number",
+}
+
+// OrderSort reads a sort such as "total" or "-ordered_at", a leading minus
+// sorting in descending order. Orders are newest first by default.
+func OrderSort(sort string) (bson.D, error) {
+	if sort == "" {
+		sort = "-ordered_at"
+	}
+	direction := 1
+	if strings.HasPrefix(sort, "-") {
+		direction = -1
+		sort = sort[1:]
+	}
+	field, ok := orderSorts[sort]
+	if !ok {
+		return nil, ErrInvalidOrderSort
+	}
+	return bson.D{primitive.E{Key: field, Value: direction}, {Key: "_id", Value: direction}}, nil
+}
+
+// orderQuery is the query of the orders matching filter. Customers are looked
+// up by phone first, so that their orders can be matched by user id.
+func orderQuery(ctx context.Context, userCollection *mongo.Collection, filter OrderFilter) (bson.D, error) {
+	query := bson.D{}
+	if filter.Status != "" {
+		query = append(query, statusFilter(filter.Status))
+	}
+	if filter.OrderNumber != "" {
+		query = append(query, primitive.E{Key: "order_number", Value: NormalizeOrderNumber(filter.OrderNumber)})
+	}
+	placed := bson.D{}
+	if !filter.From.IsZero() {
+		placed = append(placed, primitive.E{Key: "$gte", Value: filter.From})
+	}
+	if !filter.To.IsZero() {
+		placed = append(placed, primitive.E{Key: "$lt", Value: filter.To})
+	}
+	if len(placed) > 0 {
+		query = append(query, primitive.E{Key: "ordered_at", Value: placed})
+	}
+	total := bson.D{}
+	if filter.MinTotal > 0 {
+		total = append(total, primitive.E{Key: "$gte", Value: int64(filter.MinTotal)})
+	}
+	if filter.MaxTotal > 0 {
+		total = append(total, primitive.E{Key: "$lte", Value: int64(filter.MaxTotal)})
+	}
+	if len(total) > 0 {
+		query = append(query, primitive.E{Key: "total_price", Value: total})
+	}
+	if filter.PaymentMethod != "" {
+		query = append(query, paymentMethodFilter(filter.PaymentMethod))
+	}
+	if phone := strings.TrimSpace(filter.Phone); phone != "" {
+		userIds, err := customersByPhone(ctx, userCollection, phone)
+		if err != nil {
+			return nil, err
+		}
+		query = append(query, primitive.E{Key: "user_id", Value: bson.M{"$in": userIds}})
+	}
+	return query, nil
+}
+
+// paymentMethodFilter matches orders paid with method, counting orders placed
+// before methods were recorded as cash on delivery when they were.
+func paymentMethodFilter(method string) primitive.E {
+	if method == models.PaymentCOD {
+		return primitive.E{Key: "$or", Value: bson.A{
+			bson.D{primitive.E{Key: "payment_method.method", Value: method}},
+			bson.D{primitive.E{Key: "payment_method.method", Value: bson.M{"$in": bson.A{"", nil}}}, {Key: "payment_method.cod", Value: true}},
+		}}
+	}
+	return primitive.E{Key: "payment_method.method", Value: method}
+}
+
+func customersByPhone(ctx context.Context, userCollection *mongo.Collection, phone string) (bson.A, error) {
+	filter := bson.D{primitive.E{Key: "phone", Value: bson.M{"$regex": regexp.QuoteMeta(phone)}}}
+	opts := options.Find().SetProjection(bson.D{primitive.E{Key: "_id", Value: 1}})
+	cursor, err := userCollection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindOrder, err)
+	}
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindOrder, err)
+	}
+	userIds := bson.A{}
+	for _, user := range users {
+		userIds = append(userIds, user.Id.Hex())
+	}
+	return userIds, nil
+}
+
+// ListOrders returns a page of the orders matching filter in the order of sort,
+// and the number of matching orders across all pages.
+func ListOrders(ctx context.Context, orderCollection *mongo.Collection, userCollection *mongo.Collection, filter OrderFilter, sort bson.D, page int64, pageSize int64) ([]models.Order, int64, error) {
+	query, err := orderQuery(ctx, userCollection, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := orderCollection.CountDocuments(ctx, query)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	opts := options.Find().
+		SetSort(sort).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+	cursor, err := orderCollection.Find(ctx, query, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	orders := make([]models.Order, 0)
+	if err = cursor.All(ctx, &orders); err != nil {
+		log.Println(err)
+		return nil, 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	return orders, total, nil
+}
+
+// GetAdminOrder returns the order with the customer who placed it.
+func GetAdminOrder(ctx context.Context, orderCollection *mongo.Collection, userCollection *mongo.Collection, orderId primitive.ObjectID) (models.AdminOrder, error) {
+	var view models.AdminOrder
+	order, err := GetOrder(ctx, orderCollection, orderId)
+	if err != nil {
+		return view, err
+	}
+	view.Order = order
+
+	userId, err := primitive.ObjectIDFromHex(order.UserId)
+	if err != nil {
+		return view, nil
+	}
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: userId}}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return view, nil
+	}
+	if err != nil {
+		log.Println(err)
+		return view, wrapDriverError(ErrCantFindOrder, err)
+	}
+	count, err := orderCollection.CountDocuments(ctx, bson.D{primitive.E{Key: "user_id", Value: order.UserId}})
+	if err != nil {
+		log.Println(err)
+		return view, wrapDriverError(ErrCantFindOrder, err)
+	}
+	view.Customer = &models.OrderCustomer{
+		UserId:    order.UserId,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Phone:     user.Phone,
+		Orders:    count,
+	}
+	return view, nil
+}
+
+// TransitionOrders moves each of the orders to status next, one transaction per
+// order, so that an order that cannot move does not hold the others back.
+func TransitionOrders(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, orderIds []string, next models.OrderStatus, note string) ([]models.BulkOrderStatusResult, error) {
+	if !next.Valid() {
+		return nil, ErrInvalidOrderStatus
+	}
+	results := make([]models.BulkOrderStatusResult, 0, len(orderIds))
+	for _, hex := range orderIds {
+		result := models.BulkOrderStatusResult{OrderId: hex}
+		orderId, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			result.Error = ErrCantFindOrder.Error()
+			results = append(results, result)
+			continue
+		}
+		order, err := TransitionOrder(ctx, productCollection, orderCollection, paymentCollection, orderId, next, note)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Status = order.Status
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
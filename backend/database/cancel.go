@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+	ErrOrderHasShipments   = errors.New("order is already being shipped; ask the store to cancel its shipments first")
+)
+
+// CancelOrder cancels one of the user's orders that has not shipped yet. The
+// items go back to the stock, and a payment that was taken is refunded while
+// one that was only started is voided. Orders with a shipment that was neither
+// returned nor cancelled are refused, as their labels are live.
+func CancelOrder(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, shipmentCollection *mongo.Collection, userId string, orderId primitive.ObjectID, reason string) (models.Order, error) {
+	var order models.Order
+	session, err := orderCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return order, wrapDriverError(ErrCantUpdateOrder, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.D{primitive.E{Key: "_id", Value: orderId}, {Key: "user_id", Value: userId}}
+		err := orderCollection.FindOne(sessCtx, filter).Decode(&order)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindOrder, err)
+		}
+		if !order.CurrentStatus().CustomerCancellable() {
+			return nil, ErrOrderNotCancellable
+		}
+		shipments, err := orderShipments(sessCtx, shipmentCollection, orderId)
+		if err != nil {
+			return nil, err
+		}
+		for _, shipment := range shipments {
+			if shipment.Status.Active() {
+				return nil, ErrOrderHasShipments
+			}
+		}
+		return nil, transitionOrder(sessCtx, productCollection, orderCollection, paymentCollection, &order, models.OrderCancelled, reason)
+	})
+	if err == nil {
+		settleOrderPayment(ctx, paymentCollection, order.OrderId)
+	}
+	return order, err
+}
@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"log"
-	"time"
+	"strconv"
 
 	"backend/models"
 
@@ -21,143 +23,155 @@ var (
 	ErrCantRemoveItem     = errors.New("cannot remove item from cart")
 	ErrCantGetItem        = errors.New("cannot get item from cart ")
 	ErrCantBuyCartItem    = errors.New("cannot update the purchase")
+	ErrCartIsEmpty        = errors.New("cart is empty")
+	ErrCartChanged        = errors.New("cart has changed since it was last viewed")
 )
 
-func AddProductToCart(ctx context.Context, productionCollection *mongo.Collection, userCollection *mongo.Collection, productId primitive.ObjectID, userId string) error {
-	searchedFromDB, err := productionCollection.Find(ctx, bson.M{"_id": productId})
-	if err != nil {
-		log.Println(err)
-		return ErrCantFindProduct
-	}
-	var productCart []models.Product
-	err = searchedFromDB.All(ctx, &productCart)
-	if err != nil {
-		log.Println(err)
-		return ErrCantDecodeProducts
+// ResolveCart re-reads every cart line from the products collection. It returns
+// the cart with current product details, leaving out products that no longer
+// exist, together with the list of lines whose price changed or that were dropped.
+func ResolveCart(ctx context.Context, productCollection *mongo.Collection, cart []models.Product) ([]models.Product, []models.CartChange, error) {
+	resolved := make([]models.Product, 0, len(cart))
+	changes := make([]models.CartChange, 0)
+	if len(cart) == 0 {
+		return resolved, changes, nil
 	}
 
-	id, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
-		log.Println(err)
-		return ErrUserIdIsNotValid
+	ids := make([]primitive.ObjectID, 0, len(cart))
+	for _, item := range cart {
+		ids = append(ids, item.ProductId)
 	}
-
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "user_cart", Value: bson.D{{Key: "$each", Value: productCart}}}}}}
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
 	if err != nil {
-		return ErrCantUpdateUser
+		log.Println(err)
+		return nil, nil, wrapDriverError(ErrCantFindProduct, err)
 	}
-	return nil
-}
-
-func RemoveCartItem(ctx context.Context, productionCollection *mongo.Collection, userCollection *mongo.Collection, productId primitive.ObjectID, userId string) error {
-	id, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
+	var current []models.Product
+	if err = cursor.All(ctx, &current); err != nil {
 		log.Println(err)
-		return ErrUserIdIsNotValid
+		return nil, nil, wrapDriverError(ErrCantDecodeProducts, err)
 	}
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.M{"$pull": bson.M{"user_cart": bson.M{"_id": productId}}}
-	_, err = userCollection.UpdateMany(ctx, filter, update)
-	if err != nil {
-		return ErrCantRemoveItem
+	products := make(map[primitive.ObjectID]models.Product, len(current))
+	for _, product := range current {
+		products[product.ProductId] = product
 	}
-	return nil
 
+	for _, item := range cart {
+		product, ok := products[item.ProductId]
+		if !ok {
+			changes = append(changes, models.CartChange{
+				ProductId:   item.ProductId,
+				ProductName: item.ProductName,
+				Reason:      models.CartItemUnavailable,
+				OldPrice:    item.Price,
+			})
+			continue
+		}
+		if product.Price != item.Price {
+			changes = append(changes, models.CartChange{
+				ProductId:   item.ProductId,
+				ProductName: product.ProductName,
+				Reason:      models.CartPriceChanged,
+				OldPrice:    item.Price,
+				NewPrice:    product.Price,
+			})
+		}
+		product.Comments = nil
+		product.Stock = nil
+		product.Quantity = item.CartQuantity()
+		resolved = append(resolved, product)
+	}
+	return resolved, changes, nil
 }
 
-func BuyItemFromCart(ctx context.Context, userCollection *mongo.Collection, userId string, orderCollection *mongo.Collection) error {
-	usertId, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
+// CartFingerprint identifies the products, prices and quantities of a resolved
+// cart, so that a customer's confirmation of its changes holds for that cart
+// only.
+func CartFingerprint(cart []models.Product) string {
+	hash := sha256.New()
+	for _, item := range cart {
+		hash.Write([]byte(item.ProductId.Hex() + ":" + strconv.FormatUint(item.Price, 10) + ":" + strconv.FormatUint(item.CartQuantity(), 10) + ";"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// refreshCart replaces the stored cart with its resolved lines, unless the
+// cart changed since it was read.
+func refreshCart(ctx context.Context, userCollection *mongo.Collection, userId primitive.ObjectID, stored []models.Product, resolved []models.Product) {
+	filter := bson.D{primitive.E{Key: "_id", Value: userId}, {Key: "user_cart", Value: stored}}
+	update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "user_cart", Value: resolved}}}}
+	if _, err := userCollection.UpdateOne(ctx, filter, update); err != nil {
 		log.Println(err)
-		return ErrUserIdIsNotValid
-	}
-	var getCartItems models.User
-	var orderCart models.Order
-	orderCart.OrderId = primitive.NewObjectID()
-	orderCart.OrderedAt = time.Now()
-	orderCart.OrderCart = make([]models.Product, 0)
-	orderCart.PaymentMethod.COD = true
-	unwind := bson.D{{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$user_cart"}}}}
-	grouping := bson.D{{Key: "$group", Value: bson.D{primitive.E{Key: "_id", Value: "$_id"}, {Key: "total", Value: bson.D{primitive.E{Key: "$sum", Value: "$user_cart.price"}}}}}}
-	currentResults, err := userCollection.Aggregate(ctx, mongo.Pipeline{unwind, grouping})
-	ctx.Done()
-	if err != nil {
-		panic(err)
 	}
-	var getUserCart []bson.M
-	if err = currentResults.All(ctx, &getUserCart); err != nil {
-		panic(err)
-	}
-	var totalPrice int64
-	for _, userItem := range getUserCart {
-		price := userItem["total"]
-		totalPrice = price.(int64)
-	}
-	orderCart.Price = uint64(totalPrice)
-	filter := bson.D{primitive.E{Key: "_id", Value: usertId}}
-	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "orders", Value: orderCart}}}}
-	_, err = userCollection.UpdateMany(ctx, filter, update)
+}
+
+func AddProductToCart(ctx context.Context, productionCollection *mongo.Collection, userCollection *mongo.Collection, productId primitive.ObjectID, userId string, quantity uint64) error {
+	var product models.Product
+	err := productionCollection.FindOne(ctx, bson.M{"_id": productId}).Decode(&product)
 	if err != nil {
 		log.Println(err)
+		return wrapDriverError(ErrCantFindProduct, err)
 	}
-	err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: usertId}}).Decode(&getCartItems)
+
+	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		log.Println(err)
+		return wrapDriverError(ErrUserIdIsNotValid, err)
 	}
-	filter2 := bson.D{primitive.E{Key: "_id", Value: usertId}}
-	update2 := bson.M{"$push": bson.M{"orders.$[].order_list": bson.M{"$each": getCartItems.UserCart}}}
-	_, err = userCollection.UpdateOne(ctx, filter2, update2)
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&user)
 	if err != nil {
 		log.Println(err)
+		return wrapDriverError(ErrUserIdIsNotValid, err)
 	}
 
-	var newOrder models.Order = orderCart
-	newOrder.OrderCart = getCartItems.UserCart
-	_, err = orderCollection.InsertOne(ctx, newOrder)
+	product.Quantity = quantity
+	product.Comments = nil
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "user_cart", Value: mergeCartItems(user.UserCart, product)}}}}
+	_, err = userCollection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		log.Println(err)
+		return ErrCantUpdateUser
 	}
+	return nil
+}
 
-	userCartEmpty := make([]models.Product, 0)
-	filtered := bson.D{primitive.E{Key: "_id", Value: usertId}}
-	updated := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "user_cart", Value: userCartEmpty}}}}
-	_, err = userCollection.UpdateOne(ctx, filtered, updated)
-	if err != nil {
-		return ErrCantBuyCartItem
+// mergeCartItems adds items to cart, summing the quantities of lines that refer
+// to the same product up to models.MaxCartQuantity.
+func mergeCartItems(cart []models.Product, items ...models.Product) []models.Product {
+	merged := make([]models.Product, 0, len(cart)+len(items))
+	positions := make(map[primitive.ObjectID]int)
+	for _, item := range append(append([]models.Product{}, cart...), items...) {
+		if i, ok := positions[item.ProductId]; ok {
+			merged[i].Quantity = capQuantity(merged[i].CartQuantity() + capQuantity(item.CartQuantity()))
+			continue
+		}
+		item.Quantity = capQuantity(item.CartQuantity())
+		positions[item.ProductId] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+func capQuantity(quantity uint64) uint64 {
+	if quantity > models.MaxCartQuantity {
+		return models.MaxCartQuantity
 	}
-	return nil
+	return quantity
 }
 
-func InstantBuyer(ctx context.Context, productionCollection *mongo.Collection, userCollection *mongo.Collection, productId primitive.ObjectID, userId string) error {
+func RemoveCartItem(ctx context.Context, productionCollection *mongo.Collection, userCollection *mongo.Collection, productId primitive.ObjectID, userId string) error {
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		log.Println(err)
-		return ErrUserIdIsNotValid
-	}
-	var productDetails models.Product
-	var ordersDetail models.Order
-	ordersDetail.OrderId = primitive.NewObjectID()
-	ordersDetail.OrderedAt = time.Now()
-	ordersDetail.OrderCart = make([]models.Product, 0)
-	ordersDetail.PaymentMethod.COD = true
-	err = productionCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: productId}}).Decode(&productDetails)
-	if err != nil {
-		log.Println(err)
+		return wrapDriverError(ErrUserIdIsNotValid, err)
 	}
-	ordersDetail.Price = productDetails.Price
 	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "orders", Value: ordersDetail}}}}
-	_, err = userCollection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		log.Println(err)
-	}
-	filter2 := bson.D{primitive.E{Key: "_id", Value: id}}
-	update2 := bson.M{"$push": bson.M{"orders.$[].order_list": productDetails}}
-	_, err = userCollection.UpdateOne(ctx, filter2, update2)
+	update := bson.M{"$pull": bson.M{"user_cart": bson.M{"_id": productId}}}
+	_, err = userCollection.UpdateMany(ctx, filter, update)
 	if err != nil {
-		log.Println(err)
+		return ErrCantRemoveItem
 	}
 	return nil
+
 }
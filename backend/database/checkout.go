@@ -0,0 +1,304 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/divisions"
+	"backend/models"
+	"backend/payment"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrAddressNotFound      = errors.New("cannot find the address")
+	ErrAddressRequired      = errors.New("a shipping address is required")
+	ErrConflictingAddress   = errors.New("choose either a saved address or a new one, not both")
+	ErrIncompleteAddress    = errors.New("shipping address must have a house, street, district and city")
+	ErrInvalidPaymentMethod = errors.New("payment method is not supported")
+	ErrInvalidQuantity      = errors.New("quantity must be positive")
+)
+
+// BuyItemFromCart places an order for the user's cart priced against the current
+// products. When the cart has changed since it was added to, the order is only
+// placed if fingerprint is the CartFingerprint of the cart as it now stands;
+// otherwise the changes and that fingerprint are returned together with
+// ErrCartChanged. A confirmed cart keeps its new prices even when the order
+// cannot be placed.
+//
+// Reading the cart, taking the stock, authorizing the payment, recording the
+// order and emptying the cart run in one transaction, which is retried on
+// transient errors.
+func BuyItemFromCart(ctx context.Context, productCollection *mongo.Collection, userCollection *mongo.Collection, userId string, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, counterCollection *mongo.Collection, pricer *CartPricer, fingerprint string, opts models.CheckoutOptions) (models.Order, models.CartReview, error) {
+	var orderCart models.Order
+	var review models.CartReview
+	usertId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		log.Println(err)
+		return orderCart, review, wrapDriverError(ErrUserIdIsNotValid, err)
+	}
+
+	session, err := userCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return orderCart, review, wrapDriverError(ErrCantStartCheckout, err)
+	}
+	defer session.EndSession(ctx)
+
+	var stored, confirmed []models.Product
+	var intent models.PaymentIntent
+	var authorizations checkoutAuthorizations
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var getCartItems models.User
+		err := userCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: usertId}}).Decode(&getCartItems)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrUserIdIsNotValid, err)
+		}
+		cart, changes, err := ResolveCart(sessCtx, productCollection, getCartItems.UserCart)
+		if err != nil {
+			return nil, err
+		}
+		stored, confirmed = nil, nil
+		review = models.CartReview{Changes: changes, Fingerprint: CartFingerprint(cart)}
+		if len(changes) > 0 {
+			if fingerprint != review.Fingerprint {
+				return nil, ErrCartChanged
+			}
+			stored, confirmed = getCartItems.UserCart, cart
+		}
+		if len(cart) == 0 {
+			return nil, ErrCartIsEmpty
+		}
+		if opts.CouponCode == "" {
+			opts.CouponCode = getCartItems.AppliedCoupon
+		}
+
+		orderCart, intent, err = placeOrder(sessCtx, productCollection, userCollection, orderCollection, paymentCollection, counterCollection, pricer, &authorizations, getCartItems, cart, opts)
+		if err != nil {
+			return nil, err
+		}
+		filter := bson.D{primitive.E{Key: "_id", Value: usertId}}
+		update := bson.D{
+			{Key: "$set", Value: bson.D{primitive.E{Key: "user_cart", Value: make([]models.Product, 0)}}},
+			{Key: "$unset", Value: bson.D{primitive.E{Key: "applied_coupon", Value: ""}}},
+		}
+		if _, err = userCollection.UpdateOne(sessCtx, filter, update); err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantBuyCartItem, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		authorizations.release(ctx, primitive.NilObjectID)
+		if confirmed != nil {
+			refreshCart(ctx, userCollection, usertId, stored, confirmed)
+		}
+		return orderCart, review, err
+	}
+	authorizations.release(ctx, intent.IntentId)
+	captureCheckoutPayment(ctx, productCollection, orderCollection, paymentCollection, &orderCart, intent)
+	return orderCart, review, nil
+}
+
+// InstantBuyer places an order for a single product without going through the
+// user's cart, which is left untouched.
+func InstantBuyer(ctx context.Context, productCollection *mongo.Collection, userCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, counterCollection *mongo.Collection, pricer *CartPricer, productId primitive.ObjectID, quantity uint64, userId string, opts models.CheckoutOptions) (models.Order, error) {
+	var order models.Order
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		log.Println(err)
+		return order, wrapDriverError(ErrUserIdIsNotValid, err)
+	}
+	if quantity == 0 {
+		return order, ErrInvalidQuantity
+	}
+
+	session, err := userCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return order, wrapDriverError(ErrCantStartCheckout, err)
+	}
+	defer session.EndSession(ctx)
+
+	var intent models.PaymentIntent
+	var authorizations checkoutAuthorizations
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var user models.User
+		err := userCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&user)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrUserIdIsNotValid, err)
+		}
+		var productDetails models.Product
+		err = productCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: productId}}).Decode(&productDetails)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindProduct, err)
+		}
+		productDetails.Comments = nil
+		productDetails.Stock = nil
+		productDetails.Quantity = quantity
+
+		order, intent, err = placeOrder(sessCtx, productCollection, userCollection, orderCollection, paymentCollection, counterCollection, pricer, &authorizations, user, []models.Product{productDetails}, opts)
+		return nil, err
+	})
+	if err != nil {
+		authorizations.release(ctx, primitive.NilObjectID)
+		return order, err
+	}
+	authorizations.release(ctx, intent.IntentId)
+	captureCheckoutPayment(ctx, productCollection, orderCollection, paymentCollection, &order, intent)
+	return order, nil
+}
+
+// placeOrder takes the stock for items, prices them with the running
+// promotions, the chosen coupon and delivery option, numbers the order,
+// spends the user's store credit on it if asked to, authorizes the payment of
+// the rest and records it for user. It must run inside a transaction, so that
+// a failure in any step leaves the stock, the order numbers, the store credit
+// and the orders as they were. The payment is authorized through
+// authorizations, which the caller releases once the transaction is over.
+func placeOrder(ctx context.Context, productCollection *mongo.Collection, userCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, counterCollection *mongo.Collection, pricer *CartPricer, authorizations *checkoutAuthorizations, user models.User, items []models.Product, opts models.CheckoutOptions) (models.Order, models.PaymentIntent, error) {
+	var order models.Order
+	var intent models.PaymentIntent
+	address, err := checkoutAddress(user, opts)
+	if err != nil {
+		return order, intent, err
+	}
+	method, provider, err := paymentMethod(opts.PaymentMethod)
+	if err != nil {
+		return order, intent, err
+	}
+	priced, err := pricer.PriceCart(ctx, PriceRequest{UserId: user.UserId, CouponCode: opts.CouponCode, Address: address, DeliveryOption: opts.DeliveryOption}, items)
+	if err != nil {
+		return order, intent, err
+	}
+	if err = priced.Err(); err != nil {
+		return order, intent, err
+	}
+	breakdown := priced.Breakdown
+	if breakdown.CouponCode != "" {
+		if err = redeemCoupon(ctx, pricer.couponCollection, pricer.orderCollection, breakdown.CouponCode, user.UserId); err != nil {
+			return order, intent, err
+		}
+	}
+	if err = DecrementStock(ctx, productCollection, items); err != nil {
+		return order, intent, err
+	}
+
+	order.OrderId = primitive.NewObjectID()
+	order.UserId = user.UserId
+	order.OrderedAt = time.Now()
+	if order.OrderNumber, err = nextOrderNumber(ctx, counterCollection, order.OrderedAt); err != nil {
+		return order, intent, err
+	}
+	order.OrderCart = items
+	order.PaymentMethod = method
+	order.ShippingAddress = address
+	order.BillingAddress = billingAddress(user)
+	order.Pricing = breakdown
+	order.Price = order.Pricing.Total
+	order.Discount = int(order.Pricing.Discount)
+	order.Status = models.InitialOrderStatus(method)
+	if opts.UseStoreCredit {
+		if order.StoreCreditUsed, err = spendStoreCredit(ctx, userCollection, user, order.Price); err != nil {
+			return order, intent, err
+		}
+	}
+	if order.StoreCreditUsed > 0 && order.StoreCreditUsed == order.Price {
+		order.PaymentMethod = models.Payment{Method: models.PaymentStoreCredit}
+		order.Status = models.OrderPaid
+	} else if intent, err = authorizations.authorize(ctx, paymentCollection, provider, &order, opts.PaymentSource); err != nil {
+		return order, intent, err
+	}
+	order.StatusHistory = []models.StatusChange{{To: order.Status, ChangedAt: order.OrderedAt}}
+	if _, err = orderCollection.InsertOne(ctx, order); err != nil {
+		log.Println(err)
+		return order, intent, wrapDriverError(ErrCantBuyCartItem, err)
+	}
+	return order, intent, nil
+}
+
+// ShippingAddress picks the saved address the order ships to. Without an
+// address id the user's default shipping address is used, or else their first
+// saved address, if there is one.
+func ShippingAddress(user models.User, addressId string) (models.Address, error) {
+	if addressId == "" {
+		for _, address := range user.AddressDetails {
+			if address.AddressId == user.DefaultShippingAddress {
+				return address, nil
+			}
+		}
+		if len(user.AddressDetails) > 0 {
+			return user.AddressDetails[0], nil
+		}
+		return models.Address{}, nil
+	}
+	for _, address := range user.AddressDetails {
+		if address.AddressId.Hex() == addressId {
+			return address, nil
+		}
+	}
+	return models.Address{}, ErrAddressNotFound
+}
+
+// checkoutAddress is the address an order ships to: the new address given at
+// checkout, or else the chosen or first saved address. The order keeps its own
+// copy, so later changes to the address book leave it as it was.
+func checkoutAddress(user models.User, opts models.CheckoutOptions) (models.Address, error) {
+	var address models.Address
+	var err error
+	if opts.Address != nil {
+		if opts.AddressId != "" {
+			return address, ErrConflictingAddress
+		}
+		if address, err = divisions.Normalize(trimAddress(*opts.Address)); err != nil {
+			return address, err
+		}
+		address.AddressId = primitive.NilObjectID
+	} else {
+		if address, err = ShippingAddress(user, opts.AddressId); err != nil {
+			return address, err
+		}
+		if address.AddressId.IsZero() {
+			return address, ErrAddressRequired
+		}
+		address = trimAddress(address)
+	}
+	if address.House == "" || address.Street == "" || address.District == "" || address.City == "" {
+		return address, ErrIncompleteAddress
+	}
+	return address, nil
+}
+
+// billingAddress copies the user's default billing address.
+func billingAddress(user models.User) *models.Address {
+	if user.DefaultBillingAddress.IsZero() {
+		return nil
+	}
+	for _, address := range user.AddressDetails {
+		if address.AddressId == user.DefaultBillingAddress {
+			return &address
+		}
+	}
+	return nil
+}
+
+// paymentMethod looks up the provider of the payment method the customer chose,
+// cash on delivery unless they chose another.
+func paymentMethod(method string) (models.Payment, payment.Provider, error) {
+	if method == "" {
+		method = models.PaymentCOD
+	}
+	provider, err := payment.Get(method)
+	if err != nil {
+		return models.Payment{}, nil, ErrInvalidPaymentMethod
+	}
+	return models.Payment{COD: method == models.PaymentCOD, Digital: method != models.PaymentCOD, Method: method}, provider, nil
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCantNumberOrder = errors.New("cannot allocate an order number")
+
+// OrderNumberPrefix starts every order number.
+const OrderNumberPrefix = "EC"
+
+type counter struct {
+	Name  string `bson:"_id"`
+	Value uint64 `bson:"value"`
+}
+
+// nextOrderNumber allocates the next number of the year of at. Counting runs in
+// the caller's transaction, so numbers of orders that are not placed are given
+// out again and the numbers of a year have no gaps.
+func nextOrderNumber(ctx context.Context, counterCollection *mongo.Collection, at time.Time) (string, error) {
+	year := at.Year()
+	filter := bson.D{primitive.E{Key: "_id", Value: fmt.Sprintf("orders-%d", year)}}
+	update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "value", Value: 1}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var next counter
+	if err := counterCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&next); err != nil {
+		log.Println(err)
+		return "", wrapDriverError(ErrCantNumberOrder, err)
+	}
+	return fmt.Sprintf("%s-%d-%06d", OrderNumberPrefix, year, next.Value), nil
+}
+
+// NormalizeOrderNumber is the form order numbers are stored and looked up in,
+// so that they can be typed in any case.
+func NormalizeOrderNumber(number string) string {
+	return strings.ToUpper(strings.TrimSpace(number))
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase connects to the replica set in MONGODB_TEST_URI, which order
+// placement needs for its transactions, and returns a fresh database that is
+// dropped when the test ends.
+func testDatabase(t *testing.T) *mongo.Database {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("EcommerceTest%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestConcurrentOrderNumbers(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	users, products, orders := db.Collection("Users"), db.Collection("Products"), db.Collection("Orders")
+	payments, counters := db.Collection("Payments"), db.Collection("Counters")
+	pricer := NewCartPricer(db.Collection("Coupons"), db.Collection("Promotions"), db.Collection("TaxRates"), db.Collection("ShippingZones"), orders)
+
+	user := models.User{Id: primitive.NewObjectID()}
+	user.UserId = user.Id.Hex()
+	if _, err := users.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{ProductId: primitive.NewObjectID(), ProductName: "Áo thun", Price: 100000}
+	if _, err := products.InsertOne(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	opts := models.CheckoutOptions{Address: &models.Address{House: "1", Street: "Phố A", City: "Hà Nội", District: "Quận Ba Đình"}}
+
+	const placed = 20
+	numbers := make([]string, placed)
+	errs := make([]error, placed)
+	var wg sync.WaitGroup
+	for i := 0; i < placed; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order, err := InstantBuyer(ctx, products, users, orders, payments, counters, pricer, product.ProductId, 1, user.UserId, opts)
+			numbers[i], errs[i] = order.OrderNumber, err
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("order %d: %v", i, err)
+		}
+	}
+	sort.Strings(numbers)
+	year := time.Now().Year()
+	for i, number := range numbers {
+		if want := fmt.Sprintf("%s-%d-%06d", OrderNumberPrefix, year, i+1); number != want {
+			t.Errorf("got order number %s, want %s", number, want)
+		}
+	}
+}
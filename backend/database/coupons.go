@@ -0,0 +1,334 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCouponNotFound      = errors.New("coupon code is not valid")
+	ErrCouponNotStarted    = errors.New("coupon is not valid yet")
+	ErrCouponExpired       = errors.New("coupon has expired")
+	ErrCouponUsedUp        = errors.New("coupon has been used up")
+	ErrCouponUserLimit     = errors.New("coupon was already used the maximum number of times")
+	ErrCouponCodeTaken     = errors.New("coupon code is already in use")
+	ErrInvalidCouponValue  = errors.New("percentage coupons cannot take more than 10000 basis points")
+	ErrCantUpdateCoupon    = errors.New("cannot update the coupon")
+	ErrCantApplyCoupon     = errors.New("cannot apply the coupon to the cart")
+	ErrCouponNotCombinable = errors.New("coupon cannot be combined with the promotions on the cart")
+)
+
+// NormalizeCouponCode is the form coupon codes are stored and looked up in, so
+// that customers can type them in any case.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func couponFields(request models.CouponRequest) (bson.D, error) {
+	if request.Type == models.CouponPercentage && request.Value > 10000 {
+		return nil, ErrInvalidCouponValue
+	}
+	if request.ProductIds == nil {
+		request.ProductIds = make([]primitive.ObjectID, 0)
+	}
+	if request.Categories == nil {
+		request.Categories = make([]string, 0)
+	}
+	return bson.D{
+		primitive.E{Key: "code", Value: NormalizeCouponCode(request.Code)},
+		{Key: "type", Value: request.Type},
+		{Key: "value", Value: request.Value},
+		{Key: "max_discount", Value: request.MaxDiscount},
+		{Key: "min_spend", Value: request.MinSpend},
+		{Key: "starts_at", Value: request.StartsAt},
+		{Key: "ends_at", Value: request.EndsAt},
+		{Key: "usage_limit", Value: request.UsageLimit},
+		{Key: "per_user_limit", Value: request.PerUserLimit},
+		{Key: "product_ids", Value: request.ProductIds},
+		{Key: "categories", Value: request.Categories},
+		{Key: "active", Value: request.Active},
+	}, nil
+}
+
+func CreateCoupon(ctx context.Context, couponCollection *mongo.Collection, request models.CouponRequest) (models.Coupon, error) {
+	var coupon models.Coupon
+	fields, err := couponFields(request)
+	if err != nil {
+		return coupon, err
+	}
+	fields = append(fields, primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "used_count", Value: 0}, primitive.E{Key: "created_at", Value: time.Now()})
+	result, err := couponCollection.InsertOne(ctx, fields)
+	if mongo.IsDuplicateKeyError(err) {
+		return coupon, ErrCouponCodeTaken
+	}
+	if err != nil {
+		log.Println(err)
+		return coupon, wrapDriverError(ErrCantUpdateCoupon, err)
+	}
+	err = couponCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: result.InsertedID}}).Decode(&coupon)
+	if err != nil {
+		log.Println(err)
+		return coupon, wrapDriverError(ErrCouponNotFound, err)
+	}
+	return coupon, nil
+}
+
+// UpdateCoupon replaces the settings of a coupon. How often it was used is
+// kept.
+func UpdateCoupon(ctx context.Context, couponCollection *mongo.Collection, couponId primitive.ObjectID, request models.CouponRequest) (models.Coupon, error) {
+	var coupon models.Coupon
+	fields, err := couponFields(request)
+	if err != nil {
+		return coupon, err
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: couponId}}
+	update := bson.D{{Key: "$set", Value: fields}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = couponCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&coupon)
+	if mongo.IsDuplicateKeyError(err) {
+		return coupon, ErrCouponCodeTaken
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return coupon, ErrCouponNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return coupon, wrapDriverError(ErrCantUpdateCoupon, err)
+	}
+	return coupon, nil
+}
+
+func ListCoupons(ctx context.Context, couponCollection *mongo.Collection) ([]models.Coupon, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
+	cursor, err := couponCollection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCouponNotFound, err)
+	}
+	coupons := make([]models.Coupon, 0)
+	if err = cursor.All(ctx, &coupons); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCouponNotFound, err)
+	}
+	return coupons, nil
+}
+
+// couponRedemptions is the collection counting the coupon uses of each user,
+// kept in the database of the orders.
+func couponRedemptions(orderCollection *mongo.Collection) *mongo.Collection {
+	return CouponRedemptionData(orderCollection.Database().Client(), "CouponRedemptions")
+}
+
+func couponRedemptionKey(code string, userId string) string {
+	return code + ":" + userId
+}
+
+// findUsableCoupon looks up an active coupon by code and checks that it is in
+// its validity window and that neither its global nor the user's usage limit
+// has been reached. Orders that were cancelled do not count against the user.
+func findUsableCoupon(ctx context.Context, couponCollection *mongo.Collection, orderCollection *mongo.Collection, code string, userId string) (models.Coupon, error) {
+	var coupon models.Coupon
+	filter := bson.D{primitive.E{Key: "code", Value: NormalizeCouponCode(code)}, {Key: "active", Value: true}}
+	err := couponCollection.FindOne(ctx, filter).Decode(&coupon)
+	if err != nil {
+		log.Println(err)
+		return coupon, wrapDriverError(ErrCouponNotFound, err)
+	}
+	now := time.Now()
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return coupon, ErrCouponNotStarted
+	}
+	if coupon.EndsAt != nil && now.After(*coupon.EndsAt) {
+		return coupon, ErrCouponExpired
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return coupon, ErrCouponUsedUp
+	}
+	if coupon.PerUserLimit > 0 {
+		var redemption models.CouponRedemption
+		filter := bson.D{primitive.E{Key: "_id", Value: couponRedemptionKey(coupon.Code, userId)}}
+		err := couponRedemptions(orderCollection).FindOne(ctx, filter).Decode(&redemption)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println(err)
+			return coupon, wrapDriverError(ErrCantFindOrder, err)
+		}
+		if redemption.Count >= coupon.PerUserLimit {
+			return coupon, ErrCouponUserLimit
+		}
+	}
+	return coupon, nil
+}
+
+// redeemCoupon counts one use of the coupon by the user, failing if other
+// orders took the last use of the coupon or of the user first.
+func redeemCoupon(ctx context.Context, couponCollection *mongo.Collection, orderCollection *mongo.Collection, code string, userId string) error {
+	filter := bson.D{primitive.E{Key: "code", Value: code}}
+	update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "used_count", Value: 1}}}}
+	var coupon models.Coupon
+	err := couponCollection.FindOneAndUpdate(ctx, filter, update).Decode(&coupon)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateCoupon, err)
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return ErrCouponUsedUp
+	}
+	if coupon.PerUserLimit == 0 {
+		return nil
+	}
+
+	// The count only goes up while it is below the limit. A user at the limit
+	// already has a count, which the upsert then fails to insert again.
+	filter = bson.D{
+		primitive.E{Key: "_id", Value: couponRedemptionKey(code, userId)},
+		{Key: "count", Value: bson.M{"$lt": coupon.PerUserLimit}},
+	}
+	update = bson.D{
+		{Key: "$inc", Value: bson.D{primitive.E{Key: "count", Value: 1}}},
+		{Key: "$setOnInsert", Value: bson.D{primitive.E{Key: "coupon_code", Value: code}, {Key: "user_id", Value: userId}}},
+	}
+	_, err = couponRedemptions(orderCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCouponUserLimit
+	}
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateCoupon, err)
+	}
+	return nil
+}
+
+// releaseCoupon gives the coupon and the user back the use an order being
+// cancelled took. Counts never go below zero.
+func releaseCoupon(ctx context.Context, orderCollection *mongo.Collection, order models.Order) error {
+	if order.Pricing.CouponCode == "" {
+		return nil
+	}
+	filter := bson.D{primitive.E{Key: "code", Value: order.Pricing.CouponCode}, {Key: "used_count", Value: bson.M{"$gt": 0}}}
+	update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "used_count", Value: -1}}}}
+	if _, err := CouponData(orderCollection.Database().Client(), "Coupons").UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateCoupon, err)
+	}
+
+	filter = bson.D{
+		primitive.E{Key: "_id", Value: couponRedemptionKey(order.Pricing.CouponCode, order.UserId)},
+		{Key: "count", Value: bson.M{"$gt": 0}},
+	}
+	update = bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "count", Value: -1}}}}
+	if _, err := couponRedemptions(orderCollection).UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateCoupon, err)
+	}
+	return nil
+}
+
+// MigrateCouponRedemptions counts the coupon uses of orders placed before uses
+// were counted per user. Counts are only ever raised, so running it again
+// changes nothing.
+func MigrateCouponRedemptions(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			primitive.E{Key: "pricing.coupon_code", Value: bson.M{"$nin": bson.A{nil, ""}}},
+			{Key: "status", Value: bson.M{"$ne": models.OrderCancelled}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "user_id", Value: "$user_id"}, {Key: "coupon_code", Value: "$pricing.coupon_code"}}},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+		}}},
+	}
+	cursor, err := OrderData(client, "Orders").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	var uses []struct {
+		Id struct {
+			UserId     string `bson:"user_id"`
+			CouponCode string `bson:"coupon_code"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err = cursor.All(ctx, &uses); err != nil {
+		log.Println(err)
+		return
+	}
+	redemptionCollection := CouponRedemptionData(client, "CouponRedemptions")
+	for _, use := range uses {
+		filter := bson.D{primitive.E{Key: "_id", Value: couponRedemptionKey(use.Id.CouponCode, use.Id.UserId)}}
+		update := bson.D{
+			{Key: "$max", Value: bson.D{primitive.E{Key: "count", Value: use.Count}}},
+			{Key: "$setOnInsert", Value: bson.D{primitive.E{Key: "coupon_code", Value: use.Id.CouponCode}, {Key: "user_id", Value: use.Id.UserId}}},
+		}
+		if _, err = redemptionCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
+
+// ApplyCoupon checks the coupon against the user's cart and, if it can be
+// used, keeps it on the cart for checkout.
+func ApplyCoupon(ctx context.Context, productCollection *mongo.Collection, userCollection *mongo.Collection, pricer *CartPricer, userId string, code string) (models.PriceBreakdown, error) {
+	var breakdown models.PriceBreakdown
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return breakdown, ErrUserIdIsNotValid
+	}
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&user)
+	if err != nil {
+		log.Println(err)
+		return breakdown, wrapDriverError(ErrUserIdIsNotValid, err)
+	}
+	cart, _, err := ResolveCart(ctx, productCollection, user.UserCart)
+	if err != nil {
+		return breakdown, err
+	}
+	if len(cart) == 0 {
+		return breakdown, ErrCartIsEmpty
+	}
+	address, _ := ShippingAddress(user, "")
+	priced, err := pricer.PriceCart(ctx, PriceRequest{UserId: userId, CouponCode: code, Address: address}, cart)
+	if err != nil {
+		return breakdown, err
+	}
+	breakdown = priced.Breakdown
+	if priced.CouponErr != nil {
+		return breakdown, priced.CouponErr
+	}
+
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "applied_coupon", Value: breakdown.CouponCode}}}}
+	if _, err = userCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return breakdown, wrapDriverError(ErrCantApplyCoupon, err)
+	}
+	return breakdown, nil
+}
+
+func RemoveCoupon(ctx context.Context, userCollection *mongo.Collection, userId string) error {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$unset", Value: bson.D{primitive.E{Key: "applied_coupon", Value: ""}}}}
+	if _, err = userCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantApplyCoupon, err)
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -49,3 +51,187 @@ func OrderData(client *mongo.Client, collectionName string) *mongo.Collection {
 	var orderCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
 	return orderCollection
 }
+
+func GuestCartData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var guestCartCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return guestCartCollection
+}
+
+func IdempotencyData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var idempotencyCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return idempotencyCollection
+}
+
+func ReturnData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var returnCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return returnCollection
+}
+
+func PaymentData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var paymentCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return paymentCollection
+}
+
+func WebhookEventData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var webhookEventCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return webhookEventCollection
+}
+
+func CouponData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var couponCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return couponCollection
+}
+
+func PromotionData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var promotionCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return promotionCollection
+}
+
+func TaxRateData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var taxRateCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return taxRateCollection
+}
+
+func ShippingZoneData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var shippingZoneCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return shippingZoneCollection
+}
+
+func ShipmentData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var shipmentCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return shipmentCollection
+}
+
+func CounterData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var counterCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return counterCollection
+}
+
+func ExportJobData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var exportJobCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return exportJobCollection
+}
+
+func CouponRedemptionData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var couponRedemptionCollection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
+	return couponRedemptionCollection
+}
+
+// GuestCartTTL is how long a guest cart is kept after it was last updated.
+const GuestCartTTL = 7 * 24 * time.Hour
+
+func CreateIndexes(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := GuestCartData(client, "GuestCarts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "updated_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(GuestCartTTL.Seconds())),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = OrderData(client, "Orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "user_id", Value: 1}, {Key: "ordered_at", Value: -1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = OrderData(client, "Orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "status", Value: 1}, {Key: "ordered_at", Value: -1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = OrderData(client, "Orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "ordered_at", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = ReturnData(client, "Returns").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "order_id", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = PaymentData(client, "PaymentIntents").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "order_id", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = PaymentData(client, "PaymentIntents").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "provider", Value: 1}, {Key: "provider_ref", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = WebhookEventData(client, "WebhookEvents").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "received_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(WebhookEventTTL.Seconds())),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = CouponData(client, "Coupons").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = OrderData(client, "Orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "user_id", Value: 1}, {Key: "pricing.coupon_code", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = TaxRateData(client, "TaxRates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "tax_class", Value: 1}, {Key: "city", Value: 1}, {Key: "district", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = ShipmentData(client, "Shipments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "order_id", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = OrderData(client, "Orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "order_number", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = ExportJobData(client, "ExportJobs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	_, err = IdempotencyData(client, "IdempotencyKeys").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(IdempotencyKeyTTL.Seconds())),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,30 @@
+package database
+
+// driverError is a failed database call reported as one of the package's
+// errors. It reads and matches as sentinel but keeps the driver's error
+// underneath, so that transactions still see the driver's error labels and
+// retry transient failures such as write conflicts.
+type driverError struct {
+	sentinel error
+	cause    error
+}
+
+func (e driverError) Error() string {
+	return e.sentinel.Error()
+}
+
+func (e driverError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e driverError) Unwrap() error {
+	return e.cause
+}
+
+// wrapDriverError reports err as sentinel.
+func wrapDriverError(sentinel error, err error) error {
+	if err == nil {
+		return sentinel
+	}
+	return driverError{sentinel: sentinel, cause: err}
+}
@@ -0,0 +1,279 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"backend/export"
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidExportRange = errors.New("export needs a from date before its to date")
+	ErrCantFindExport     = errors.New("cannot find the export")
+	ErrCantCreateExport   = errors.New("cannot create the export")
+	ErrExportNotReady     = errors.New("export is not ready")
+)
+
+// ExportStreamLimit is the most orders an export streams straight away. Larger
+// exports are written in the background.
+const ExportStreamLimit = 5000
+
+// exportJobTimeout is how long a background export may take.
+const exportJobTimeout = 30 * time.Minute
+
+// CheckExport reports whether request can be exported.
+func CheckExport(request models.ExportRequest) error {
+	if request.From.IsZero() || request.To.IsZero() || !request.From.Before(request.To) {
+		return ErrInvalidExportRange
+	}
+	if request.Format != export.FormatCSV && request.Format != export.FormatXLSX {
+		return export.ErrUnknownFormat
+	}
+	_, err := export.Columns(request.Dataset, request.Columns)
+	return err
+}
+
+// ExportFileName names the file of request, such as
+// orders-2026-01-01-2026-02-01.xlsx.
+func ExportFileName(request models.ExportRequest) string {
+	return fmt.Sprintf("%s-%s-%s.%s", request.Dataset, request.From.Format("2006-01-02"), request.To.Format("2006-01-02"), request.Format)
+}
+
+func exportFilter(request models.ExportRequest) bson.D {
+	return bson.D{primitive.E{Key: "ordered_at", Value: bson.D{
+		primitive.E{Key: "$gte", Value: request.From},
+		{Key: "$lt", Value: request.To},
+	}}}
+}
+
+// CountExport counts the orders request covers.
+func CountExport(ctx context.Context, orderCollection *mongo.Collection, request models.ExportRequest) (int64, error) {
+	count, err := orderCollection.CountDocuments(ctx, exportFilter(request))
+	if err != nil {
+		log.Println(err)
+		return 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	return count, nil
+}
+
+// WriteExport writes the spreadsheet of request to w as the orders are read,
+// oldest first, and returns how many rows it holds beneath the header.
+func WriteExport(ctx context.Context, orderCollection *mongo.Collection, request models.ExportRequest, w io.Writer) (int64, error) {
+	columns, err := export.Columns(request.Dataset, request.Columns)
+	if err != nil {
+		return 0, err
+	}
+	sheet, err := export.NewWriter(request.Format, w)
+	if err != nil {
+		return 0, err
+	}
+	if err = sheet.WriteRow(export.Header(columns)); err != nil {
+		return 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "ordered_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := orderCollection.Find(ctx, exportFilter(request), opts)
+	if err != nil {
+		log.Println(err)
+		return 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	defer cursor.Close(ctx)
+	var rows int64
+	for cursor.Next(ctx) {
+		var order models.Order
+		if err = cursor.Decode(&order); err != nil {
+			log.Println(err)
+			return rows, wrapDriverError(ErrCantFindOrder, err)
+		}
+		if request.Dataset == export.DatasetLines {
+			for _, line := range OrderBreakdown(order).Lines {
+				if err = sheet.WriteRow(export.Values(columns, export.Row{Order: order, Line: line})); err != nil {
+					return rows, err
+				}
+				rows++
+			}
+			continue
+		}
+		if err = sheet.WriteRow(export.Values(columns, export.Row{Order: order})); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	if err = cursor.Err(); err != nil {
+		log.Println(err)
+		return rows, wrapDriverError(ErrCantFindOrder, err)
+	}
+	return rows, sheet.Close()
+}
+
+// exportFiles is the bucket the files of export jobs are kept in, next to the
+// jobs.
+func exportFiles(exportCollection *mongo.Collection) (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(exportCollection.Database(), options.GridFSBucket().SetName("ExportFiles"))
+}
+
+// StartExport records a job for request and writes its file in the
+// background.
+func StartExport(orderCollection *mongo.Collection, exportCollection *mongo.Collection, request models.ExportRequest) (models.ExportJob, error) {
+	job := models.ExportJob{
+		JobId:         primitive.NewObjectID(),
+		ExportRequest: request,
+		Status:        models.ExportPending,
+		CreatedAt:     time.Now(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := exportCollection.InsertOne(ctx, job); err != nil {
+		log.Println(err)
+		return job, wrapDriverError(ErrCantCreateExport, err)
+	}
+	go runExport(orderCollection, exportCollection, job)
+	return job, nil
+}
+
+func runExport(orderCollection *mongo.Collection, exportCollection *mongo.Collection, job models.ExportJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportJobTimeout)
+	defer cancel()
+	setExportFields(ctx, exportCollection, job.JobId, bson.D{primitive.E{Key: "status", Value: models.ExportRunning}})
+
+	rows, fileId, err := uploadExport(ctx, orderCollection, exportCollection, job.ExportRequest)
+	fields := bson.D{primitive.E{Key: "finished_at", Value: time.Now()}, {Key: "rows", Value: rows}}
+	if err != nil {
+		log.Println(err)
+		fields = append(fields, primitive.E{Key: "status", Value: models.ExportFailed}, primitive.E{Key: "error", Value: err.Error()})
+	} else {
+		fields = append(fields, primitive.E{Key: "status", Value: models.ExportReady}, primitive.E{Key: "file_id", Value: fileId})
+	}
+	setExportFields(ctx, exportCollection, job.JobId, fields)
+}
+
+// uploadExport writes the file of request into the export bucket. Files that
+// fail part way are removed.
+func uploadExport(ctx context.Context, orderCollection *mongo.Collection, exportCollection *mongo.Collection, request models.ExportRequest) (int64, primitive.ObjectID, error) {
+	bucket, err := exportFiles(exportCollection)
+	if err != nil {
+		return 0, primitive.NilObjectID, err
+	}
+	upload, err := bucket.OpenUploadStream(ExportFileName(request))
+	if err != nil {
+		return 0, primitive.NilObjectID, err
+	}
+	rows, err := WriteExport(ctx, orderCollection, request, upload)
+	if err != nil {
+		if abortErr := upload.Abort(); abortErr != nil {
+			log.Println(abortErr)
+		}
+		return rows, primitive.NilObjectID, err
+	}
+	if err = upload.Close(); err != nil {
+		return rows, primitive.NilObjectID, err
+	}
+	fileId, _ := upload.FileID.(primitive.ObjectID)
+	return rows, fileId, nil
+}
+
+func setExportFields(ctx context.Context, exportCollection *mongo.Collection, jobId primitive.ObjectID, fields bson.D) {
+	filter := bson.D{primitive.E{Key: "_id", Value: jobId}}
+	update := bson.D{{Key: "$set", Value: fields}}
+	if _, err := exportCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+	}
+}
+
+func GetExportJob(ctx context.Context, exportCollection *mongo.Collection, jobId primitive.ObjectID) (models.ExportJob, error) {
+	var job models.ExportJob
+	err := exportCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: jobId}}).Decode(&job)
+	if err != nil {
+		log.Println(err)
+		return job, wrapDriverError(ErrCantFindExport, err)
+	}
+	return job, nil
+}
+
+// ListExportJobs returns the latest export jobs, newest first.
+func ListExportJobs(ctx context.Context, exportCollection *mongo.Collection, limit int64) ([]models.ExportJob, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := exportCollection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindExport, err)
+	}
+	jobs := make([]models.ExportJob, 0)
+	if err = cursor.All(ctx, &jobs); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindExport, err)
+	}
+	return jobs, nil
+}
+
+// OpenExport opens the file of a ready job for reading.
+func OpenExport(exportCollection *mongo.Collection, job models.ExportJob) (*gridfs.DownloadStream, error) {
+	if job.Status != models.ExportReady {
+		return nil, ErrExportNotReady
+	}
+	bucket, err := exportFiles(exportCollection)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindExport, err)
+	}
+	file, err := bucket.OpenDownloadStream(job.FileId)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindExport, err)
+	}
+	return file, nil
+}
+
+// DeleteExportJob removes a finished job and its file.
+func DeleteExportJob(ctx context.Context, exportCollection *mongo.Collection, jobId primitive.ObjectID) error {
+	job, err := GetExportJob(ctx, exportCollection, jobId)
+	if err != nil {
+		return err
+	}
+	if job.Status == models.ExportPending || job.Status == models.ExportRunning {
+		return ErrExportNotReady
+	}
+	if !job.FileId.IsZero() {
+		bucket, err := exportFiles(exportCollection)
+		if err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrCantFindExport, err)
+		}
+		if err = bucket.Delete(job.FileId); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			log.Println(err)
+			return wrapDriverError(ErrCantFindExport, err)
+		}
+	}
+	if _, err = exportCollection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: jobId}}); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantFindExport, err)
+	}
+	return nil
+}
+
+// FailInterruptedExports marks the jobs that were being written when the
+// server stopped as failed, as nothing will finish them.
+func FailInterruptedExports(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	filter := bson.D{primitive.E{Key: "status", Value: bson.M{"$in": bson.A{models.ExportPending, models.ExportRunning}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		primitive.E{Key: "status", Value: models.ExportFailed},
+		{Key: "error", Value: "export was interrupted"},
+		{Key: "finished_at", Value: time.Now()},
+	}}}
+	if _, err := ExportJobData(client, "ExportJobs").UpdateMany(ctx, filter, update); err != nil {
+		log.Println(err)
+	}
+}
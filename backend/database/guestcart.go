@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrCartIdIsNotValid   = errors.New("guest cart is not valid")
+	ErrCantFindGuestCart  = errors.New("cannot find the guest cart")
+	ErrCantCreateCart     = errors.New("cannot create the guest cart")
+	ErrCantUpdateCart     = errors.New("cannot update the guest cart")
+	ErrCantMergeGuestCart = errors.New("cannot merge the guest cart")
+)
+
+func CreateGuestCart(ctx context.Context, guestCartCollection *mongo.Collection) (models.GuestCart, error) {
+	cart := models.GuestCart{
+		CartId:    primitive.NewObjectID(),
+		Items:     make([]models.Product, 0),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	_, err := guestCartCollection.InsertOne(ctx, cart)
+	if err != nil {
+		log.Println(err)
+		return cart, wrapDriverError(ErrCantCreateCart, err)
+	}
+	return cart, nil
+}
+
+func GetGuestCart(ctx context.Context, guestCartCollection *mongo.Collection, cartId string) (models.GuestCart, error) {
+	var cart models.GuestCart
+	id, err := primitive.ObjectIDFromHex(cartId)
+	if err != nil {
+		log.Println(err)
+		return cart, wrapDriverError(ErrCartIdIsNotValid, err)
+	}
+	err = guestCartCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&cart)
+	if err != nil {
+		log.Println(err)
+		return cart, wrapDriverError(ErrCantFindGuestCart, err)
+	}
+	return cart, nil
+}
+
+func AddProductToGuestCart(ctx context.Context, productCollection *mongo.Collection, guestCartCollection *mongo.Collection, productId primitive.ObjectID, cartId string, quantity uint64) error {
+	var product models.Product
+	err := productCollection.FindOne(ctx, bson.M{"_id": productId}).Decode(&product)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantFindProduct, err)
+	}
+	cart, err := GetGuestCart(ctx, guestCartCollection, cartId)
+	if err != nil {
+		return err
+	}
+
+	product.Quantity = quantity
+	product.Comments = nil
+	filter := bson.D{primitive.E{Key: "_id", Value: cart.CartId}}
+	update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "items", Value: mergeCartItems(cart.Items, product)}, {Key: "updated_at", Value: time.Now()}}}}
+	_, err = guestCartCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateCart, err)
+	}
+	return nil
+}
+
+func RemoveGuestCartItem(ctx context.Context, guestCartCollection *mongo.Collection, productId primitive.ObjectID, cartId string) error {
+	id, err := primitive.ObjectIDFromHex(cartId)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCartIdIsNotValid, err)
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.M{"$pull": bson.M{"items": bson.M{"_id": productId}}, "$set": bson.M{"updated_at": time.Now()}}
+	_, err = guestCartCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantRemoveItem, err)
+	}
+	return nil
+}
+
+// MergeGuestCart moves the guest cart into the user's cart, summing the
+// quantities of products present in both, and deletes the guest cart.
+func MergeGuestCart(ctx context.Context, guestCartCollection *mongo.Collection, userCollection *mongo.Collection, cartId string, userId string) error {
+	cart, err := GetGuestCart(ctx, guestCartCollection, cartId)
+	if err != nil {
+		return err
+	}
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrUserIdIsNotValid, err)
+	}
+	if len(cart.Items) > 0 {
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&user)
+		if err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrUserIdIsNotValid, err)
+		}
+		filter := bson.D{primitive.E{Key: "_id", Value: id}}
+		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "user_cart", Value: mergeCartItems(user.UserCart, cart.Items...)}}}}
+		_, err = userCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrCantMergeGuestCart, err)
+		}
+	}
+	_, err = guestCartCollection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: cart.CartId}})
+	if err != nil {
+		log.Println(err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrCantSaveIdempotencyKey   = errors.New("cannot save the idempotency key")
+)
+
+// IdempotencyKeyTTL is how long a request fingerprint and its response are kept.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyLease is how long a request holds its key before a retry may take
+// it over. It outlasts the handlers' own timeouts.
+const IdempotencyLease = 2 * time.Minute
+
+// BeginIdempotentRequest claims key for the request identified by fingerprint.
+// It returns the id of the lease on the key when the request should run. When
+// the same request already completed, its stored record is returned instead.
+// A request whose lease ran out, such as one whose server stopped, is taken
+// over.
+func BeginIdempotentRequest(ctx context.Context, idempotencyCollection *mongo.Collection, key string, fingerprint string) (*models.IdempotencyRecord, primitive.ObjectID, error) {
+	now := time.Now()
+	record := models.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		LeaseId:     primitive.NewObjectID(),
+		LockedUntil: now.Add(IdempotencyLease),
+		CreatedAt:   now,
+	}
+	_, err := idempotencyCollection.InsertOne(ctx, record)
+	if err == nil {
+		return nil, record.LeaseId, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		log.Println(err)
+		return nil, primitive.NilObjectID, wrapDriverError(ErrCantSaveIdempotencyKey, err)
+	}
+
+	var stored models.IdempotencyRecord
+	err = idempotencyCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: key}}).Decode(&stored)
+	if err != nil {
+		log.Println(err)
+		return nil, primitive.NilObjectID, wrapDriverError(ErrCantSaveIdempotencyKey, err)
+	}
+	if stored.Fingerprint != fingerprint {
+		return nil, primitive.NilObjectID, ErrIdempotencyKeyReused
+	}
+	if stored.Completed {
+		return &stored, primitive.NilObjectID, nil
+	}
+	if stored.LockedUntil.After(now) {
+		return nil, primitive.NilObjectID, ErrIdempotencyKeyInProgress
+	}
+
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: key},
+		{Key: "completed", Value: false},
+		{Key: "$or", Value: bson.A{
+			bson.D{primitive.E{Key: "locked_until", Value: bson.M{"$lte": now}}},
+			bson.D{primitive.E{Key: "locked_until", Value: bson.M{"$exists": false}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		primitive.E{Key: "lease_id", Value: record.LeaseId},
+		{Key: "locked_until", Value: record.LockedUntil},
+	}}}
+	result, err := idempotencyCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return nil, primitive.NilObjectID, wrapDriverError(ErrCantSaveIdempotencyKey, err)
+	}
+	if result.ModifiedCount == 0 {
+		return nil, primitive.NilObjectID, ErrIdempotencyKeyInProgress
+	}
+	return nil, record.LeaseId, nil
+}
+
+// CompleteIdempotentRequest stores the response of the request holding lease on
+// key. A request whose key was taken over stores nothing.
+func CompleteIdempotentRequest(ctx context.Context, idempotencyCollection *mongo.Collection, key string, lease primitive.ObjectID, responseCode int, responseBody []byte) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: key}, {Key: "lease_id", Value: lease}}
+	update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "completed", Value: true}, {Key: "response_code", Value: responseCode}, {Key: "response_body", Value: responseBody}}}}
+	_, err := idempotencyCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantSaveIdempotencyKey, err)
+	}
+	return nil
+}
+
+// ReleaseIdempotentRequest forgets key, if the request still holds lease on
+// it, so that the request can be retried.
+func ReleaseIdempotentRequest(ctx context.Context, idempotencyCollection *mongo.Collection, key string, lease primitive.ObjectID) error {
+	_, err := idempotencyCollection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: key}, {Key: "lease_id", Value: lease}, {Key: "completed", Value: false}})
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantSaveIdempotencyKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrOutOfStock        = errors.New("not enough stock for the product")
+	ErrTooManyUnits      = errors.New("too many units of the product")
+	ErrCantUpdateStock   = errors.New("cannot update the product stock")
+	ErrCantStartCheckout = errors.New("cannot start the checkout")
+)
+
+// DecrementStock takes the ordered quantities out of the products' stock. Products
+// without a stock level are not inventory tracked and are always available.
+func DecrementStock(ctx context.Context, productCollection *mongo.Collection, items []models.Product) error {
+	for _, item := range items {
+		if item.CartQuantity() > models.MaxCartQuantity {
+			return fmt.Errorf("%w: %s", ErrTooManyUnits, item.ProductName)
+		}
+		quantity := int64(item.CartQuantity())
+		filter := bson.D{primitive.E{Key: "_id", Value: item.ProductId}, {Key: "stock", Value: bson.M{"$gte": quantity}}}
+		update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "stock", Value: -quantity}}}}
+		result, err := productCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrCantUpdateStock, err)
+		}
+		if result.MatchedCount > 0 {
+			continue
+		}
+		untracked, err := productCollection.CountDocuments(ctx, bson.D{primitive.E{Key: "_id", Value: item.ProductId}, {Key: "stock", Value: bson.M{"$exists": false}}})
+		if err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrCantUpdateStock, err)
+		}
+		if untracked == 0 {
+			return fmt.Errorf("%w: %s", ErrOutOfStock, item.ProductName)
+		}
+	}
+	return nil
+}
+
+// IncrementStock puts the quantities of items back into the products' stock.
+func IncrementStock(ctx context.Context, productCollection *mongo.Collection, items []models.Product) error {
+	for _, item := range items {
+		quantity := int64(item.CartQuantity())
+		filter := bson.D{primitive.E{Key: "_id", Value: item.ProductId}, {Key: "stock", Value: bson.M{"$exists": true}}}
+		update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "stock", Value: quantity}}}}
+		if _, err := productCollection.UpdateOne(ctx, filter, update); err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrCantUpdateStock, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindOrder      = errors.New("cannot find the order")
+	ErrCantUpdateOrder    = errors.New("cannot update the order")
+	ErrInvalidOrderStatus = errors.New("order status is not valid")
+	ErrIllegalTransition  = errors.New("order cannot move to this status")
+	ErrCantRecordRefund   = errors.New("cannot record the refund")
+)
+
+// TransitionOrder moves the order to status next and records the change in its
+// status history. Moves that the order status table does not allow are rejected.
+func TransitionOrder(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, orderId primitive.ObjectID, next models.OrderStatus, note string) (models.Order, error) {
+	var order models.Order
+	if !next.Valid() {
+		return order, ErrInvalidOrderStatus
+	}
+
+	session, err := orderCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return order, wrapDriverError(ErrCantUpdateOrder, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		err := orderCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: orderId}}).Decode(&order)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindOrder, err)
+		}
+		return nil, transitionOrder(sessCtx, productCollection, orderCollection, paymentCollection, &order, next, note)
+	})
+	if err == nil {
+		settleOrderPayment(ctx, paymentCollection, order.OrderId)
+	}
+	return order, err
+}
+
+// transitionOrder moves order to status next within the caller's transaction.
+// Cancelled orders give their items back to the stock and their coupon use back
+// to the user, and settle their payment, moving on to refunded when money had
+// to be paid back. Delivered orders capture payments that wait for delivery.
+// The provider calls are queued on the payment intent, and the caller makes
+// them with settleOrderPayment once the transaction commits.
+func transitionOrder(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, order *models.Order, next models.OrderStatus, note string) error {
+	current := order.CurrentStatus()
+	if !current.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, current, next)
+	}
+	fields := bson.D{primitive.E{Key: "status", Value: next}}
+	var cancellation models.Cancellation
+	switch next {
+	case models.OrderCancelled:
+		if err := IncrementStock(ctx, productCollection, order.OrderCart); err != nil {
+			return err
+		}
+		if err := releaseCoupon(ctx, orderCollection, *order); err != nil {
+			return err
+		}
+		action, err := settleCancelledPayment(ctx, orderCollection, paymentCollection, *order)
+		if err != nil {
+			return err
+		}
+		if order.StoreCreditUsed > 0 {
+			if err = refundStoreCredit(ctx, orderCollection, storeCreditUsers(orderCollection), *order, order.StoreCreditUsed, "cancel-credit-"+order.OrderId.Hex()); err != nil {
+				return err
+			}
+			if action == models.PaymentActionNone {
+				action = models.PaymentActionRefund
+			}
+		}
+		cancellation = models.Cancellation{Reason: note, CancelledAt: time.Now(), PaymentAction: action}
+		order.Cancellation = &cancellation
+		fields = append(fields, primitive.E{Key: "cancellation", Value: cancellation})
+	case models.OrderDelivered:
+		if err := captureOnDelivery(ctx, paymentCollection, *order); err != nil {
+			return err
+		}
+	}
+
+	change := models.StatusChange{From: current, To: next, ChangedAt: time.Now(), Note: note}
+	order.Status = next
+	order.StatusHistory = append(order.StatusHistory, change)
+
+	filter := bson.D{primitive.E{Key: "_id", Value: order.OrderId}}
+	update := bson.D{
+		{Key: "$set", Value: fields},
+		{Key: "$push", Value: bson.D{primitive.E{Key: "status_history", Value: change}}},
+	}
+	if _, err := orderCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateOrder, err)
+	}
+	if cancellation.PaymentAction == models.PaymentActionRefund {
+		return transitionOrder(ctx, productCollection, orderCollection, paymentCollection, order, models.OrderRefunded, "Refunded on cancellation")
+	}
+	return nil
+}
+
+// recordRefund adds a refund paid back for the order to its refund history.
+func recordRefund(ctx context.Context, orderCollection *mongo.Collection, orderId primitive.ObjectID, refund models.Refund) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: orderId}}
+	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "refunds", Value: refund}}}}
+	if _, err := orderCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantRecordRefund, err)
+	}
+	return nil
+}
+
+// ListUserOrders returns a page of the user's orders, newest first, optionally
+// limited to the orders in status, and the number of orders across all pages.
+func ListUserOrders(ctx context.Context, orderCollection *mongo.Collection, userId string, status models.OrderStatus, orderNumber string, page int64, pageSize int64) ([]models.Order, int64, error) {
+	filter := bson.D{primitive.E{Key: "user_id", Value: userId}}
+	if status != "" {
+		filter = append(filter, statusFilter(status))
+	}
+	if orderNumber != "" {
+		filter = append(filter, primitive.E{Key: "order_number", Value: NormalizeOrderNumber(orderNumber)})
+	}
+	total, err := orderCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "ordered_at", Value: -1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+	cursor, err := orderCollection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	orders := make([]models.Order, 0)
+	if err = cursor.All(ctx, &orders); err != nil {
+		log.Println(err)
+		return nil, 0, wrapDriverError(ErrCantFindOrder, err)
+	}
+	return orders, total, nil
+}
+
+// statusFilter matches orders in status, counting orders placed before
+// statuses were recorded as processing.
+func statusFilter(status models.OrderStatus) primitive.E {
+	if status == models.OrderProcessing {
+		return primitive.E{Key: "status", Value: bson.M{"$in": bson.A{status, nil}}}
+	}
+	return primitive.E{Key: "status", Value: status}
+}
+
+// GetUserOrder returns the order only if it belongs to the user, so that one
+// customer cannot read another's orders.
+func GetUserOrder(ctx context.Context, orderCollection *mongo.Collection, userId string, orderId primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	filter := bson.D{primitive.E{Key: "_id", Value: orderId}, {Key: "user_id", Value: userId}}
+	err := orderCollection.FindOne(ctx, filter).Decode(&order)
+	if err != nil {
+		log.Println(err)
+		return order, wrapDriverError(ErrCantFindOrder, err)
+	}
+	return order, nil
+}
+
+func GetOrder(ctx context.Context, orderCollection *mongo.Collection, orderId primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	err := orderCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: orderId}}).Decode(&order)
+	if err != nil {
+		log.Println(err)
+		return order, wrapDriverError(ErrCantFindOrder, err)
+	}
+	return order, nil
+}
+
+// MigrateEmbeddedOrders moves the orders users used to keep a copy of into the
+// orders collection, recording who placed them, and drops the copies.
+func MigrateEmbeddedOrders(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	userCollection := UserData(client, "Users")
+	orderCollection := OrderData(client, "Orders")
+
+	var users []struct {
+		Id     primitive.ObjectID `bson:"_id"`
+		Orders []models.Order     `bson:"orders"`
+	}
+	cursor, err := userCollection.Find(ctx, bson.M{"orders": bson.M{"$exists": true}}, options.Find().SetProjection(bson.M{"orders": 1}))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err = cursor.All(ctx, &users); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, user := range users {
+		for _, order := range user.Orders {
+			order.UserId = user.Id.Hex()
+			filter := bson.D{primitive.E{Key: "_id", Value: order.OrderId}}
+			update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "user_id", Value: order.UserId}}}}
+			result, err := orderCollection.UpdateOne(ctx, filter, update)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if result.MatchedCount == 0 {
+				if _, err = orderCollection.InsertOne(ctx, order); err != nil {
+					log.Println(err)
+					return
+				}
+			}
+		}
+		filter := bson.D{primitive.E{Key: "_id", Value: user.Id}}
+		update := bson.D{{Key: "$unset", Value: bson.D{primitive.E{Key: "orders", Value: ""}}}}
+		if _, err = userCollection.UpdateOne(ctx, filter, update); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/payment"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindPayment   = errors.New("cannot find the payment")
+	ErrCantUpdatePayment = errors.New("cannot update the payment")
+	ErrPaymentDeclined   = errors.New("payment was declined")
+)
+
+// orderPaymentIntent loads the payment intent of order together with its
+// provider. Orders placed before payments had intents have none, which is
+// reported with a nil provider.
+func orderPaymentIntent(ctx context.Context, paymentCollection *mongo.Collection, order models.Order) (models.PaymentIntent, payment.Provider, error) {
+	var intent models.PaymentIntent
+	if order.PaymentMethod.IntentId.IsZero() {
+		return intent, nil, nil
+	}
+	err := paymentCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: order.PaymentMethod.IntentId}}).Decode(&intent)
+	if err != nil {
+		log.Println(err)
+		return intent, nil, wrapDriverError(ErrCantFindPayment, err)
+	}
+	provider, err := payment.Get(intent.Provider)
+	if err != nil {
+		return intent, nil, err
+	}
+	return intent, provider, nil
+}
+
+func savePaymentIntent(ctx context.Context, paymentCollection *mongo.Collection, intent *models.PaymentIntent) error {
+	intent.UpdatedAt = time.Now()
+	filter := bson.D{primitive.E{Key: "_id", Value: intent.IntentId}}
+	_, err := paymentCollection.ReplaceOne(ctx, filter, intent, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdatePayment, err)
+	}
+	return nil
+}
+
+func GetPaymentIntent(ctx context.Context, paymentCollection *mongo.Collection, orderId primitive.ObjectID) (models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	err := paymentCollection.FindOne(ctx, bson.D{primitive.E{Key: "order_id", Value: orderId}}).Decode(&intent)
+	if err != nil {
+		log.Println(err)
+		return intent, wrapDriverError(ErrCantFindPayment, err)
+	}
+	return intent, nil
+}
+
+// checkoutAuthorizations keeps the authorizations made by the attempts of one
+// checkout transaction. A retried attempt reuses an authorization of the same
+// amount instead of making another, and those left unused are voided once the
+// transaction is over, so that no provider call is undone from inside it.
+type checkoutAuthorizations struct {
+	intents []models.PaymentIntent
+}
+
+// authorize opens the payment intent of a new order for what its store credit
+// leaves to pay and has the provider authorize it from source, unless an
+// earlier attempt already did for the same provider and amount.
+func (a *checkoutAuthorizations) authorize(ctx context.Context, paymentCollection *mongo.Collection, provider payment.Provider, order *models.Order, source string) (models.PaymentIntent, error) {
+	amount := order.Price - order.StoreCreditUsed
+	for i, intent := range a.intents {
+		if intent.Status != models.PaymentAuthorized || intent.Provider != provider.Name() || intent.Amount != amount {
+			continue
+		}
+		intent.OrderId = order.OrderId
+		intent.UserId = order.UserId
+		if err := savePaymentIntent(ctx, paymentCollection, &intent); err != nil {
+			return intent, err
+		}
+		a.intents[i] = intent
+		order.PaymentMethod.IntentId = intent.IntentId
+		return intent, nil
+	}
+
+	intent := models.PaymentIntent{
+		IntentId:  primitive.NewObjectID(),
+		OrderId:   order.OrderId,
+		UserId:    order.UserId,
+		Provider:  provider.Name(),
+		Amount:    amount,
+		Currency:  "VND",
+		Status:    models.PaymentPending,
+		CreatedAt: time.Now(),
+	}
+	err := provider.Authorize(ctx, &intent, source)
+	a.intents = append(a.intents, intent)
+	if err != nil {
+		if errors.Is(err, payment.ErrDeclined) {
+			return intent, fmt.Errorf("%w: %s", ErrPaymentDeclined, intent.FailureReason)
+		}
+		log.Println(err)
+		return intent, wrapDriverError(ErrCantUpdatePayment, err)
+	}
+	if err = savePaymentIntent(ctx, paymentCollection, &intent); err != nil {
+		return intent, err
+	}
+	order.PaymentMethod.IntentId = intent.IntentId
+	return intent, nil
+}
+
+// release voids the authorizations whose order was never recorded, which are
+// all of them except kept.
+func (a *checkoutAuthorizations) release(ctx context.Context, kept primitive.ObjectID) {
+	for i := range a.intents {
+		intent := &a.intents[i]
+		if intent.IntentId == kept || intent.Status != models.PaymentAuthorized {
+			continue
+		}
+		provider, err := payment.Get(intent.Provider)
+		if err == nil {
+			err = provider.Void(ctx, intent)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// captureCheckoutPayment captures the payment of a placed order whose provider
+// does not wait for delivery, and marks the order paid. A failed capture leaves
+// the order pending payment with its authorization in place, as does one the
+// provider confirms later through a webhook.
+func captureCheckoutPayment(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, order *models.Order, intent models.PaymentIntent) {
+	provider, err := payment.Get(intent.Provider)
+	if err != nil || provider.DeferredCapture() {
+		return
+	}
+	if err = provider.Capture(ctx, &intent); err != nil {
+		if !errors.Is(err, payment.ErrPaymentPending) {
+			log.Println(err)
+		}
+		return
+	}
+	if err = savePaymentIntent(ctx, paymentCollection, &intent); err != nil {
+		return
+	}
+	paid, err := TransitionOrder(ctx, productCollection, orderCollection, paymentCollection, order.OrderId, models.OrderPaid, "Payment captured")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	*order = paid
+}
+
+// captureOnDelivery queues the capture of the payment of an order being
+// delivered when its provider waits for delivery, such as cash on delivery.
+func captureOnDelivery(ctx context.Context, paymentCollection *mongo.Collection, order models.Order) error {
+	intent, provider, err := orderPaymentIntent(ctx, paymentCollection, order)
+	if err != nil || provider == nil || !provider.DeferredCapture() || intent.Status != models.PaymentAuthorized {
+		return err
+	}
+	return queuePaymentOperation(ctx, paymentCollection, &intent, models.PaymentActionCapture, 0, "")
+}
+
+// settleCancelledPayment queues the void of the payment of an order being
+// cancelled, or the refund of what was captured of it, and reports which it
+// did.
+func settleCancelledPayment(ctx context.Context, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, order models.Order) (string, error) {
+	intent, provider, err := orderPaymentIntent(ctx, paymentCollection, order)
+	if err != nil {
+		return "", err
+	}
+	if provider == nil {
+		return models.PaymentActionNone, nil
+	}
+	switch intent.Status {
+	case models.PaymentPending, models.PaymentAuthorized:
+		if err = queuePaymentOperation(ctx, paymentCollection, &intent, models.PaymentActionVoid, 0, ""); err != nil {
+			return "", err
+		}
+		return models.PaymentActionVoid, nil
+	case models.PaymentCaptured, models.PaymentPartiallyRefunded:
+		amount := refundableAmount(intent)
+		if amount == 0 {
+			return models.PaymentActionNone, nil
+		}
+		if err = refundPayment(ctx, orderCollection, paymentCollection, order, amount, models.RefundToOriginalPayment, "cancel-"+order.OrderId.Hex()); err != nil {
+			return "", err
+		}
+		return models.PaymentActionRefund, nil
+	}
+	return models.PaymentActionNone, nil
+}
+
+// refundPayment queues the refund of amount of the order through its payment
+// provider and records the refund on the order. Orders without a payment
+// intent are refunded by the store outside of any provider, so only the record
+// is kept. Reference must stay the same for the same refund, as the provider
+// uses it to recognise a repeated call.
+func refundPayment(ctx context.Context, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, order models.Order, amount uint64, method string, reference string) error {
+	if method == models.RefundToOriginalPayment {
+		intent, provider, err := orderPaymentIntent(ctx, paymentCollection, order)
+		if err != nil {
+			return err
+		}
+		if provider != nil {
+			if intent.Status != models.PaymentCaptured && intent.Status != models.PaymentPartiallyRefunded {
+				return fmt.Errorf("%w: %v", ErrCantUpdatePayment, payment.ErrInvalidPaymentState)
+			}
+			if amount > refundableAmount(intent) {
+				return fmt.Errorf("%w: %v", ErrCantUpdatePayment, payment.ErrRefundExceedsPayment)
+			}
+			if err = queuePaymentOperation(ctx, paymentCollection, &intent, models.PaymentActionRefund, amount, reference); err != nil {
+				return err
+			}
+		}
+	}
+	return recordRefund(ctx, orderCollection, order.OrderId, models.Refund{
+		Amount:     amount,
+		Method:     method,
+		Reference:  reference,
+		RefundedAt: time.Now(),
+	})
+}
+
+// refundableAmount is what is left to refund of a captured payment once the
+// refunds already queued for it are paid.
+func refundableAmount(intent models.PaymentIntent) uint64 {
+	amount := intent.Amount - intent.RefundedAmount
+	for _, operation := range intent.Pending {
+		if operation.Action != models.PaymentActionRefund {
+			continue
+		}
+		if operation.Amount >= amount {
+			return 0
+		}
+		amount -= operation.Amount
+	}
+	return amount
+}
+
+// queuePaymentOperation records on the intent a provider call to make once the
+// caller's transaction commits.
+func queuePaymentOperation(ctx context.Context, paymentCollection *mongo.Collection, intent *models.PaymentIntent, action string, amount uint64, reference string) error {
+	operation := models.PaymentOperation{
+		OperationId: primitive.NewObjectID(),
+		Action:      action,
+		Amount:      amount,
+		Reference:   reference,
+		QueuedAt:    time.Now(),
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: intent.IntentId}}
+	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "pending", Value: operation}}}}
+	if _, err := paymentCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdatePayment, err)
+	}
+	intent.Pending = append(intent.Pending, operation)
+	return nil
+}
+
+// settleOrderPayment makes the provider calls queued on the payment of the
+// order. It runs after the transaction that queued them has committed; calls
+// that fail stay queued for RetryPaymentOperations to make later.
+func settleOrderPayment(ctx context.Context, paymentCollection *mongo.Collection, orderId primitive.ObjectID) {
+	var intent models.PaymentIntent
+	err := paymentCollection.FindOne(ctx, bson.D{primitive.E{Key: "order_id", Value: orderId}}).Decode(&intent)
+	if err == nil {
+		err = runPaymentOperations(ctx, paymentCollection, intent)
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Println(err)
+	}
+}
+
+// runPaymentOperations makes the provider calls queued on intent in order,
+// removing each from the queue together with the change it made. A call the
+// provider refuses for the state of the payment can never succeed and is
+// dropped; any other failure stops the run and leaves the rest queued.
+func runPaymentOperations(ctx context.Context, paymentCollection *mongo.Collection, intent models.PaymentIntent) error {
+	if len(intent.Pending) == 0 {
+		return nil
+	}
+	provider, err := payment.Get(intent.Provider)
+	if err != nil {
+		return err
+	}
+	for _, operation := range intent.Pending {
+		before := intent
+		switch operation.Action {
+		case models.PaymentActionCapture:
+			err = provider.Capture(ctx, &intent)
+		case models.PaymentActionVoid:
+			err = provider.Void(ctx, &intent)
+		case models.PaymentActionRefund:
+			err = provider.Refund(ctx, &intent, operation.Amount, operation.Reference)
+		}
+		switch {
+		case err == nil, errors.Is(err, payment.ErrPaymentPending):
+		case errors.Is(err, payment.ErrInvalidPaymentState), errors.Is(err, payment.ErrRefundExceedsPayment):
+			log.Println(err)
+			intent = before
+		default:
+			log.Println(err)
+			return wrapDriverError(ErrCantUpdatePayment, err)
+		}
+
+		filter := bson.D{primitive.E{Key: "_id", Value: intent.IntentId}, {Key: "pending._id", Value: operation.OperationId}}
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				primitive.E{Key: "status", Value: intent.Status},
+				{Key: "refunded_amount", Value: intent.RefundedAmount},
+				{Key: "failure_reason", Value: intent.FailureReason},
+				{Key: "updated_at", Value: time.Now()},
+			}},
+			{Key: "$pull", Value: bson.D{primitive.E{Key: "pending", Value: bson.D{primitive.E{Key: "_id", Value: operation.OperationId}}}}},
+		}
+		result, err := paymentCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Println(err)
+			return wrapDriverError(ErrCantUpdatePayment, err)
+		}
+		if result.ModifiedCount == 0 {
+			// Another run made this call first and carries on from here.
+			return nil
+		}
+	}
+	return nil
+}
+
+// PaymentRetryInterval is how often provider calls left queued are retried.
+// Calls queued more recently are left to the request that queued them.
+const PaymentRetryInterval = time.Minute
+
+// RetryPaymentOperations makes the provider calls that were left queued when
+// a run failed or the server stopped, and keeps doing so every
+// PaymentRetryInterval. It never returns.
+func RetryPaymentOperations(client *mongo.Client) {
+	ticker := time.NewTicker(PaymentRetryInterval)
+	defer ticker.Stop()
+	for {
+		retryPaymentOperations(client, time.Now().Add(-PaymentRetryInterval))
+		<-ticker.C
+	}
+}
+
+// retryPaymentOperations makes the provider calls of the payments that have a
+// call queued before queuedBefore.
+func retryPaymentOperations(client *mongo.Client, queuedBefore time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	filter := bson.D{primitive.E{Key: "pending.queued_at", Value: bson.M{"$lt": queuedBefore}}}
+	cursor, err := PaymentData(client, "PaymentIntents").Find(ctx, filter)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	var intents []models.PaymentIntent
+	if err = cursor.All(ctx, &intents); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, intent := range intents {
+		if err = runPaymentOperations(ctx, PaymentData(client, "PaymentIntents"), intent); err != nil {
+			log.Println(err)
+		}
+	}
+}
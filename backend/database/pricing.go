@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"backend/carrier"
+	"backend/models"
+	"backend/pricing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CartPricer prices carts with the promotions, coupons, tax rates and shipping
+// zones kept in the database.
+type CartPricer struct {
+	couponCollection       *mongo.Collection
+	promotionCollection    *mongo.Collection
+	taxRateCollection      *mongo.Collection
+	shippingZoneCollection *mongo.Collection
+	orderCollection        *mongo.Collection
+}
+
+func NewCartPricer(couponCollection *mongo.Collection, promotionCollection *mongo.Collection, taxRateCollection *mongo.Collection, shippingZoneCollection *mongo.Collection, orderCollection *mongo.Collection) *CartPricer {
+	return &CartPricer{
+		couponCollection:       couponCollection,
+		promotionCollection:    promotionCollection,
+		taxRateCollection:      taxRateCollection,
+		shippingZoneCollection: shippingZoneCollection,
+		orderCollection:        orderCollection,
+	}
+}
+
+// PriceRequest says how a cart is to be priced. UserId may be empty for
+// guests, who cannot use coupons.
+type PriceRequest struct {
+	UserId         string
+	CouponCode     string
+	Address        models.Address
+	DeliveryOption string
+}
+
+// PricedCart is a priced cart. When the coupon or the delivery option cannot
+// be used the breakdown leaves them out and CouponErr or DeliveryErr says why,
+// so that callers can still show the cart; checkout refuses to go on.
+type PricedCart struct {
+	Breakdown   models.PriceBreakdown
+	Quotes      []models.ShippingQuote
+	CouponErr   error
+	DeliveryErr error
+}
+
+// Err is the first reason the cart cannot be bought as requested.
+func (p PricedCart) Err() error {
+	if p.CouponErr != nil {
+		return p.CouponErr
+	}
+	return p.DeliveryErr
+}
+
+// PriceCart prices the items with the running promotions, the coupon, the tax
+// rates of the address and the delivery option. Stores without shipping zones
+// charge no shipping.
+func (p *CartPricer) PriceCart(ctx context.Context, request PriceRequest, items []models.Product) (PricedCart, error) {
+	var priced PricedCart
+	promotions, err := activePromotions(ctx, p.promotionCollection)
+	if err != nil {
+		return priced, err
+	}
+	rates, err := ListTaxRates(ctx, p.taxRateCollection)
+	if err != nil {
+		return priced, err
+	}
+	zones, err := ListShippingZones(ctx, p.shippingZoneCollection)
+	if err != nil {
+		return priced, err
+	}
+	opts := pricing.Options{
+		Promotions: pricing.ApplyPromotions(promotions, items, time.Now()),
+		TaxRates:   rates,
+		Address:    request.Address,
+	}
+	if request.CouponCode != "" {
+		opts.CouponCode, opts.CouponDiscount, priced.CouponErr = p.couponDiscount(ctx, request, promotions, opts.Promotions, items)
+	}
+
+	if len(zones) > 0 {
+		goods, err := pricing.Calculate(items, opts)
+		if err != nil {
+			return priced, err
+		}
+		priced.Quotes, priced.DeliveryErr = pricing.QuoteShipping(zones, request.Address, items, goods.Subtotal-goods.Discount)
+		priced.Quotes = carrierQuotes(ctx, priced.Quotes, request.Address, items, goods.Subtotal-goods.Discount)
+		if priced.DeliveryErr == nil {
+			option := request.DeliveryOption
+			if option == "" {
+				option = models.DeliveryStandard
+			}
+			var quote models.ShippingQuote
+			quote, priced.DeliveryErr = pricing.ChooseDelivery(priced.Quotes, option)
+			if priced.DeliveryErr == nil {
+				opts.Shipping = quote.Cost
+				priced.Breakdown.Delivery = &quote
+			}
+		}
+	}
+	delivery := priced.Breakdown.Delivery
+	if priced.Breakdown, err = pricing.Calculate(items, opts); err != nil {
+		return priced, err
+	}
+	priced.Breakdown.Delivery = delivery
+	return priced, nil
+}
+
+// carrierQuotes asks the carriers of the quotes priced by a carrier for their
+// price. Quotes a carrier cannot give are left out.
+func carrierQuotes(ctx context.Context, quotes []models.ShippingQuote, address models.Address, items []models.Product, goodsValue uint64) []models.ShippingQuote {
+	quoted := quotes[:0]
+	for _, quote := range quotes {
+		if quote.Carrier == "" {
+			quoted = append(quoted, quote)
+			continue
+		}
+		shipper, err := carrier.Get(quote.Carrier)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		parcel := carrier.Parcel{Option: quote.Option, To: address, Weight: pricing.CartWeight(items), Value: goodsValue}
+		price, err := shipper.Quote(ctx, parcel)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !quote.Free {
+			quote.Cost = price.Cost
+		}
+		if quote.MinDays == 0 && quote.MaxDays == 0 {
+			quote.MinDays, quote.MaxDays = price.MinDays, price.MaxDays
+		}
+		quoted = append(quoted, quote)
+	}
+	return quoted
+}
+
+func (p *CartPricer) couponDiscount(ctx context.Context, request PriceRequest, promotions []models.Promotion, applied []models.AppliedPromotion, items []models.Product) (string, uint64, error) {
+	if !pricing.CombinesWithCoupons(promotions, applied) {
+		return "", 0, ErrCouponNotCombinable
+	}
+	coupon, err := findUsableCoupon(ctx, p.couponCollection, p.orderCollection, request.CouponCode, request.UserId)
+	if err != nil {
+		return "", 0, err
+	}
+	discount, err := pricing.CouponDiscount(coupon, items, applied)
+	if err != nil {
+		return "", 0, err
+	}
+	return coupon.Code, discount, nil
+}
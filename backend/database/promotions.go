@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindPromotion   = errors.New("cannot find the promotion")
+	ErrCantUpdatePromotion = errors.New("cannot update the promotion")
+	ErrInvalidPromotion    = errors.New("promotion is missing the settings of its type")
+)
+
+func promotionFields(request models.PromotionRequest) (bson.D, error) {
+	switch request.Type {
+	case models.PromotionBuyXGetY:
+		if request.BuyQuantity == 0 || request.FreeQuantity == 0 {
+			return nil, ErrInvalidPromotion
+		}
+	case models.PromotionTiered:
+		if len(request.Tiers) == 0 {
+			return nil, ErrInvalidPromotion
+		}
+	case models.PromotionBundle:
+		if len(request.BundleItems) == 0 {
+			return nil, ErrInvalidPromotion
+		}
+		for _, item := range request.BundleItems {
+			if item.Quantity == 0 {
+				return nil, ErrInvalidPromotion
+			}
+		}
+	}
+	if request.ProductIds == nil {
+		request.ProductIds = make([]primitive.ObjectID, 0)
+	}
+	if request.Categories == nil {
+		request.Categories = make([]string, 0)
+	}
+	return bson.D{
+		primitive.E{Key: "name", Value: request.Name},
+		{Key: "type", Value: request.Type},
+		{Key: "priority", Value: request.Priority},
+		{Key: "stackable", Value: request.Stackable},
+		{Key: "combines_with_coupons", Value: request.CombinesWithCoupons},
+		{Key: "active", Value: request.Active},
+		{Key: "starts_at", Value: request.StartsAt},
+		{Key: "ends_at", Value: request.EndsAt},
+		{Key: "product_ids", Value: request.ProductIds},
+		{Key: "categories", Value: request.Categories},
+		{Key: "buy_quantity", Value: request.BuyQuantity},
+		{Key: "free_quantity", Value: request.FreeQuantity},
+		{Key: "tiers", Value: request.Tiers},
+		{Key: "bundle_items", Value: request.BundleItems},
+		{Key: "bundle_price", Value: request.BundlePrice},
+	}, nil
+}
+
+func CreatePromotion(ctx context.Context, promotionCollection *mongo.Collection, request models.PromotionRequest) (models.Promotion, error) {
+	var promotion models.Promotion
+	fields, err := promotionFields(request)
+	if err != nil {
+		return promotion, err
+	}
+	fields = append(fields, primitive.E{Key: "_id", Value: primitive.NewObjectID()}, primitive.E{Key: "created_at", Value: time.Now()})
+	result, err := promotionCollection.InsertOne(ctx, fields)
+	if err != nil {
+		log.Println(err)
+		return promotion, wrapDriverError(ErrCantUpdatePromotion, err)
+	}
+	err = promotionCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: result.InsertedID}}).Decode(&promotion)
+	if err != nil {
+		log.Println(err)
+		return promotion, wrapDriverError(ErrCantFindPromotion, err)
+	}
+	return promotion, nil
+}
+
+func UpdatePromotion(ctx context.Context, promotionCollection *mongo.Collection, promotionId primitive.ObjectID, request models.PromotionRequest) (models.Promotion, error) {
+	var promotion models.Promotion
+	fields, err := promotionFields(request)
+	if err != nil {
+		return promotion, err
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: promotionId}}
+	update := bson.D{{Key: "$set", Value: fields}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = promotionCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&promotion)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return promotion, ErrCantFindPromotion
+	}
+	if err != nil {
+		log.Println(err)
+		return promotion, wrapDriverError(ErrCantUpdatePromotion, err)
+	}
+	return promotion, nil
+}
+
+func ListPromotions(ctx context.Context, promotionCollection *mongo.Collection) ([]models.Promotion, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}})
+	cursor, err := promotionCollection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindPromotion, err)
+	}
+	promotions := make([]models.Promotion, 0)
+	if err = cursor.All(ctx, &promotions); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindPromotion, err)
+	}
+	return promotions, nil
+}
+
+// activePromotions returns the promotions that are switched on. Their
+// validity windows are checked when they are applied.
+func activePromotions(ctx context.Context, promotionCollection *mongo.Collection) ([]models.Promotion, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}})
+	cursor, err := promotionCollection.Find(ctx, bson.D{primitive.E{Key: "active", Value: true}}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindPromotion, err)
+	}
+	promotions := make([]models.Promotion, 0)
+	if err = cursor.All(ctx, &promotions); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindPromotion, err)
+	}
+	return promotions, nil
+}
@@ -0,0 +1,401 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/pricing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrOrderNotReturnable    = errors.New("only delivered orders can be returned")
+	ErrReturnWindowClosed    = errors.New("the return window for this order has closed")
+	ErrInvalidReturnQuantity = errors.New("return quantity exceeds what can still be returned")
+	ErrCantFindReturn        = errors.New("cannot find the return")
+	ErrCantUpdateReturn      = errors.New("cannot update the return")
+	ErrIllegalReturnDecision = errors.New("return cannot move to this status")
+	ErrCantRefundStoreCredit = errors.New("cannot add the refund to the store credit")
+)
+
+// OrderBreakdown is the price breakdown of order. Orders placed before prices
+// were broken down had no adjustments, so their breakdown is their cart's.
+func OrderBreakdown(order models.Order) models.PriceBreakdown {
+	if len(order.Pricing.Lines) > 0 {
+		return order.Pricing
+	}
+	breakdown, err := pricing.Calculate(order.OrderCart, pricing.Options{})
+	if err != nil {
+		log.Println(err)
+	}
+	return breakdown
+}
+
+// deliveredAt is when order was delivered, from its status history.
+func deliveredAt(order models.Order) time.Time {
+	for i := len(order.StatusHistory) - 1; i >= 0; i-- {
+		if order.StatusHistory[i].To == models.OrderDelivered {
+			return order.StatusHistory[i].ChangedAt
+		}
+	}
+	return order.OrderedAt
+}
+
+// returnedQuantities sums the quantities per product of the order's returns
+// that are in one of statuses.
+func returnedQuantities(ctx context.Context, returnCollection *mongo.Collection, orderId primitive.ObjectID, statuses ...models.ReturnStatus) (map[primitive.ObjectID]uint64, error) {
+	filter := bson.D{primitive.E{Key: "order_id", Value: orderId}, {Key: "status", Value: bson.M{"$in": statuses}}}
+	cursor, err := returnCollection.Find(ctx, filter)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindReturn, err)
+	}
+	var returns []models.Return
+	if err = cursor.All(ctx, &returns); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindReturn, err)
+	}
+	quantities := make(map[primitive.ObjectID]uint64)
+	for _, orderReturn := range returns {
+		for _, line := range orderReturn.Lines {
+			quantities[line.ProductId] += line.Quantity
+		}
+	}
+	return quantities, nil
+}
+
+// RequestReturn opens a return for lines of one of the user's delivered orders.
+// Lines cannot ask for more than was ordered less what other open or approved
+// returns of the order already cover, which is checked and counted on the
+// order in one conditional update.
+func RequestReturn(ctx context.Context, orderCollection *mongo.Collection, returnCollection *mongo.Collection, userId string, orderId primitive.ObjectID, request models.ReturnRequest) (models.Return, error) {
+	var orderReturn models.Return
+	session, err := returnCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return orderReturn, wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var order models.Order
+		filter := bson.D{primitive.E{Key: "_id", Value: orderId}, {Key: "user_id", Value: userId}}
+		err := orderCollection.FindOne(sessCtx, filter).Decode(&order)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindOrder, err)
+		}
+		if order.CurrentStatus() != models.OrderDelivered {
+			return nil, ErrOrderNotReturnable
+		}
+		if time.Since(deliveredAt(order)) > models.ReturnWindow {
+			return nil, ErrReturnWindowClosed
+		}
+
+		breakdown := OrderBreakdown(order)
+		ordered := make(map[primitive.ObjectID]models.OrderLine)
+		for _, line := range breakdown.Lines {
+			ordered[line.ProductId] = line
+		}
+		requested := make(map[primitive.ObjectID]uint64)
+		lines := make([]models.ReturnLine, 0, len(request.Lines))
+		for _, line := range request.Lines {
+			orderLine, ok := ordered[line.ProductId]
+			requested[line.ProductId] += line.Quantity
+			if !ok || requested[line.ProductId] > orderLine.Quantity {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidReturnQuantity, line.ProductId.Hex())
+			}
+			line.ProductName = orderLine.ProductName
+			lines = append(lines, line)
+		}
+		if err = reserveReturn(sessCtx, orderCollection, returnCollection, order, ordered, requested); err != nil {
+			return nil, err
+		}
+
+		orderReturn = models.Return{
+			ReturnId:     primitive.NewObjectID(),
+			OrderId:      orderId,
+			UserId:       userId,
+			Lines:        lines,
+			Reason:       request.Reason,
+			Photos:       request.Photos,
+			RefundMethod: request.RefundMethod,
+			RefundAmount: pricing.RefundAmount(breakdown, requested),
+			Status:       models.ReturnRequested,
+			RequestedAt:  time.Now(),
+		}
+		if orderReturn.RefundMethod == "" {
+			orderReturn.RefundMethod = models.RefundToOriginalPayment
+		}
+		if orderReturn.Photos == nil {
+			orderReturn.Photos = make([]string, 0)
+		}
+		if _, err = returnCollection.InsertOne(sessCtx, orderReturn); err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantUpdateReturn, err)
+		}
+		return nil, nil
+	})
+	return orderReturn, err
+}
+
+// reserveReturn adds the units requested for return to the order's count of
+// returned units. The check that the units are still available is the filter
+// of the same update, so two requests cannot both take them. Orders whose
+// returns were not counted yet are counted from their returns first.
+func reserveReturn(ctx context.Context, orderCollection *mongo.Collection, returnCollection *mongo.Collection, order models.Order, ordered map[primitive.ObjectID]models.OrderLine, requested map[primitive.ObjectID]uint64) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: order.OrderId}}
+	var update bson.D
+	if order.ReturnedQuantities == nil {
+		returned, err := returnedQuantities(ctx, returnCollection, order.OrderId, models.ReturnRequested, models.ReturnReceived, models.ReturnApproved)
+		if err != nil {
+			return err
+		}
+		counts := make(map[string]uint64, len(returned)+len(requested))
+		for productId, quantity := range returned {
+			counts[productId.Hex()] = quantity
+		}
+		for productId, quantity := range requested {
+			if counts[productId.Hex()]+quantity > ordered[productId].Quantity {
+				return fmt.Errorf("%w: %s", ErrInvalidReturnQuantity, productId.Hex())
+			}
+			counts[productId.Hex()] += quantity
+		}
+		filter = append(filter, primitive.E{Key: "returned_qty", Value: bson.M{"$exists": false}})
+		update = bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "returned_qty", Value: counts}}}}
+	} else {
+		increments := bson.D{}
+		for productId, quantity := range requested {
+			key := "returned_qty." + productId.Hex()
+			filter = append(filter, primitive.E{Key: key, Value: bson.M{"$not": bson.M{"$gt": ordered[productId].Quantity - quantity}}})
+			increments = append(increments, primitive.E{Key: key, Value: int64(quantity)})
+		}
+		update = bson.D{{Key: "$inc", Value: increments}}
+	}
+	result, err := orderCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidReturnQuantity
+	}
+	return nil
+}
+
+// releaseReturn gives the units of a rejected return back to what can still
+// be returned of its order.
+func releaseReturn(ctx context.Context, orderCollection *mongo.Collection, orderReturn models.Return) error {
+	quantities := make(map[primitive.ObjectID]uint64)
+	for _, line := range orderReturn.Lines {
+		quantities[line.ProductId] += line.Quantity
+	}
+	increments := bson.D{}
+	for productId, quantity := range quantities {
+		increments = append(increments, primitive.E{Key: "returned_qty." + productId.Hex(), Value: -int64(quantity)})
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: orderReturn.OrderId}, {Key: "returned_qty", Value: bson.M{"$exists": true}}}
+	update := bson.D{{Key: "$inc", Value: increments}}
+	if _, err := orderCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	return nil
+}
+
+func ListReturns(ctx context.Context, returnCollection *mongo.Collection, filter bson.D) ([]models.Return, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "requested_at", Value: -1}})
+	cursor, err := returnCollection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindReturn, err)
+	}
+	returns := make([]models.Return, 0)
+	if err = cursor.All(ctx, &returns); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindReturn, err)
+	}
+	return returns, nil
+}
+
+// decideReturn moves a return from one of the statuses in from to status to.
+func decideReturn(ctx context.Context, returnCollection *mongo.Collection, returnId primitive.ObjectID, from []models.ReturnStatus, to models.ReturnStatus, note string) (models.Return, error) {
+	var orderReturn models.Return
+	now := time.Now()
+	fields := bson.D{primitive.E{Key: "status", Value: to}, {Key: "admin_note", Value: note}}
+	if to == models.ReturnReceived {
+		fields = append(fields, primitive.E{Key: "received_at", Value: now})
+	} else {
+		fields = append(fields, primitive.E{Key: "decided_at", Value: now})
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: returnId}, {Key: "status", Value: bson.M{"$in": from}}}
+	update := bson.D{{Key: "$set", Value: fields}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := returnCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&orderReturn)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, countErr := returnCollection.CountDocuments(ctx, bson.D{primitive.E{Key: "_id", Value: returnId}})
+		if countErr == nil && count > 0 {
+			return orderReturn, ErrIllegalReturnDecision
+		}
+		return orderReturn, ErrCantFindReturn
+	}
+	if err != nil {
+		log.Println(err)
+		return orderReturn, wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	return orderReturn, nil
+}
+
+// ReceiveReturn records that the goods of a requested return arrived.
+func ReceiveReturn(ctx context.Context, returnCollection *mongo.Collection, returnId primitive.ObjectID, note string) (models.Return, error) {
+	return decideReturn(ctx, returnCollection, returnId, []models.ReturnStatus{models.ReturnRequested}, models.ReturnReceived, note)
+}
+
+// RejectReturn rejects a return that was not approved yet, so that its units
+// can be returned again.
+func RejectReturn(ctx context.Context, orderCollection *mongo.Collection, returnCollection *mongo.Collection, returnId primitive.ObjectID, note string) (models.Return, error) {
+	var orderReturn models.Return
+	session, err := returnCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return orderReturn, wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var err error
+		orderReturn, err = decideReturn(sessCtx, returnCollection, returnId, []models.ReturnStatus{models.ReturnRequested, models.ReturnReceived}, models.ReturnRejected, note)
+		if err != nil {
+			return nil, err
+		}
+		return nil, releaseReturn(sessCtx, orderCollection, orderReturn)
+	})
+	return orderReturn, err
+}
+
+// ApproveReturn approves a return whose goods were received. The goods go back
+// to the stock and the refund is paid to the original payment method or added
+// to the user's store credit. Once every line of the order has been returned
+// the order itself is marked refunded.
+func ApproveReturn(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, userCollection *mongo.Collection, returnCollection *mongo.Collection, returnId primitive.ObjectID, note string) (models.Return, error) {
+	var orderReturn models.Return
+	session, err := returnCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return orderReturn, wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var err error
+		orderReturn, err = decideReturn(sessCtx, returnCollection, returnId, []models.ReturnStatus{models.ReturnReceived}, models.ReturnApproved, note)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]models.Product, 0, len(orderReturn.Lines))
+		for _, line := range orderReturn.Lines {
+			items = append(items, models.Product{ProductId: line.ProductId, ProductName: line.ProductName, Quantity: line.Quantity})
+		}
+		if err = IncrementStock(sessCtx, productCollection, items); err != nil {
+			return nil, err
+		}
+
+		var order models.Order
+		err = orderCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: orderReturn.OrderId}}).Decode(&order)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindOrder, err)
+		}
+		if err = capReturnRefund(sessCtx, returnCollection, order, &orderReturn); err != nil {
+			return nil, err
+		}
+		if err = refundReturn(sessCtx, orderCollection, paymentCollection, userCollection, order, orderReturn); err != nil {
+			return nil, err
+		}
+
+		returned, err := returnedQuantities(sessCtx, returnCollection, order.OrderId, models.ReturnApproved)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range OrderBreakdown(order).Lines {
+			if returned[line.ProductId] < line.Quantity {
+				return nil, nil
+			}
+		}
+		return nil, transitionOrder(sessCtx, productCollection, orderCollection, paymentCollection, &order, models.OrderRefunded, "All items returned")
+	})
+	if err == nil {
+		settleOrderPayment(ctx, paymentCollection, orderReturn.OrderId)
+	}
+	return orderReturn, err
+}
+
+// capReturnRefund lowers the refund of a return to what is left of the order's
+// total once its earlier refunds are paid, however they were paid, and keeps
+// the lowered amount on the return. Refunds to the store credit are capped the
+// same as refunds to the original payment.
+func capReturnRefund(ctx context.Context, returnCollection *mongo.Collection, order models.Order, orderReturn *models.Return) error {
+	remaining := order.Price
+	for _, refund := range order.Refunds {
+		if refund.Amount >= remaining {
+			remaining = 0
+			break
+		}
+		remaining -= refund.Amount
+	}
+	if orderReturn.RefundAmount <= remaining {
+		return nil
+	}
+	orderReturn.RefundAmount = remaining
+	filter := bson.D{primitive.E{Key: "_id", Value: orderReturn.ReturnId}}
+	update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "refund_amount", Value: remaining}}}}
+	if _, err := returnCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateReturn, err)
+	}
+	return nil
+}
+
+// refundReturn pays back the refund of an approved return, either to the
+// user's store credit or to the payment the order was paid with. Orders paid
+// partly with store credit are refunded to their payment up to what it took,
+// and the rest goes back to the store credit.
+func refundReturn(ctx context.Context, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, userCollection *mongo.Collection, order models.Order, orderReturn models.Return) error {
+	reference := orderReturn.ReturnId.Hex()
+	if orderReturn.RefundMethod == models.RefundToStoreCredit {
+		if err := addStoreCredit(ctx, userCollection, orderReturn.UserId, orderReturn.RefundAmount); err != nil {
+			return err
+		}
+		return refundPayment(ctx, orderCollection, paymentCollection, order, orderReturn.RefundAmount, orderReturn.RefundMethod, reference)
+	}
+
+	amount := orderReturn.RefundAmount
+	if order.StoreCreditUsed > 0 {
+		intent, provider, err := orderPaymentIntent(ctx, paymentCollection, order)
+		if err != nil {
+			return err
+		}
+		var paid uint64
+		if provider != nil {
+			paid = refundableAmount(intent)
+		}
+		if amount > paid {
+			if err = refundStoreCredit(ctx, orderCollection, userCollection, order, amount-paid, reference+"-credit"); err != nil {
+				return err
+			}
+			amount = paid
+		}
+		if amount == 0 {
+			return nil
+		}
+	}
+	return refundPayment(ctx, orderCollection, paymentCollection, order, amount, orderReturn.RefundMethod, reference)
+}
@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/carrier"
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrOrderNotShippable       = errors.New("only paid orders that are being processed can be shipped")
+	ErrInvalidShipmentQuantity = errors.New("shipment quantity exceeds what is left to ship")
+	ErrNothingToShip           = errors.New("every item of the order has already been shipped")
+	ErrCantFindShipment        = errors.New("cannot find the shipment")
+	ErrCantUpdateShipment      = errors.New("cannot update the shipment")
+	ErrShipmentClosed          = errors.New("shipment was already delivered, returned or cancelled")
+	ErrShipmentNotCancellable  = errors.New("only shipments that are still being packed can be cancelled")
+	ErrCarrierUnavailable      = errors.New("cannot reach the carrier")
+)
+
+// orderedQuantities sums the quantities per product of the order's cart.
+func orderedQuantities(order models.Order) (map[primitive.ObjectID]uint64, map[primitive.ObjectID]string) {
+	quantities := make(map[primitive.ObjectID]uint64)
+	names := make(map[primitive.ObjectID]string)
+	for _, item := range order.OrderCart {
+		quantities[item.ProductId] += item.CartQuantity()
+		names[item.ProductId] = item.ProductName
+	}
+	return quantities, names
+}
+
+func orderShipments(ctx context.Context, shipmentCollection *mongo.Collection, orderId primitive.ObjectID) ([]models.Shipment, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: 1}})
+	cursor, err := shipmentCollection.Find(ctx, bson.D{primitive.E{Key: "order_id", Value: orderId}}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindShipment, err)
+	}
+	shipments := make([]models.Shipment, 0)
+	if err = cursor.All(ctx, &shipments); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindShipment, err)
+	}
+	return shipments, nil
+}
+
+// shippedQuantities sums the quantities per product of the shipments for which
+// include holds.
+func shippedQuantities(shipments []models.Shipment, include func(models.ShipmentStatus) bool) map[primitive.ObjectID]uint64 {
+	quantities := make(map[primitive.ObjectID]uint64)
+	for _, shipment := range shipments {
+		if !include(shipment.Status) {
+			continue
+		}
+		for _, line := range shipment.Lines {
+			quantities[line.ProductId] += line.Quantity
+		}
+	}
+	return quantities
+}
+
+// shipmentParcel describes the parcel carrying lines of order.
+func shipmentParcel(order models.Order, lines []models.ShipmentLine, trackingNumber string) carrier.Parcel {
+	parcel := carrier.Parcel{Option: models.DeliveryStandard, To: order.ShippingAddress, TrackingNumber: trackingNumber}
+	if order.Pricing.Delivery != nil {
+		parcel.Option = order.Pricing.Delivery.Option
+	}
+	products := make(map[primitive.ObjectID]models.Product)
+	for _, item := range order.OrderCart {
+		products[item.ProductId] = item
+	}
+	for _, line := range lines {
+		parcel.Weight += products[line.ProductId].Weight * line.Quantity
+		parcel.Value += products[line.ProductId].Price * line.Quantity
+	}
+	return parcel
+}
+
+// shipmentCarrier is the carrier a shipment of order goes with: the one asked
+// for, else the one of the customer's delivery option, else the manual one.
+func shipmentCarrier(order models.Order, name string) (carrier.Carrier, error) {
+	if name == "" && order.Pricing.Delivery != nil {
+		name = order.Pricing.Delivery.Carrier
+	}
+	if name == "" {
+		name = carrier.Manual
+	}
+	return carrier.Get(name)
+}
+
+// releaseLabel cancels a label whose shipment was never recorded.
+func releaseLabel(ctx context.Context, shipment *models.Shipment) {
+	if shipment.TrackingNumber == "" || shipment.Carrier == "" {
+		return
+	}
+	shipper, err := carrier.Get(shipment.Carrier)
+	if err == nil {
+		err = shipper.Cancel(ctx, shipment.TrackingNumber)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	shipment.TrackingNumber = ""
+}
+
+// CreateShipment packs lines of a paid order into a new shipment, has its
+// carrier issue a label and moves the order to processing. Lines cannot ask
+// for more than was ordered less what other shipments of the order carry,
+// unless those were returned or cancelled.
+func CreateShipment(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, shipmentCollection *mongo.Collection, orderId primitive.ObjectID, request models.ShipmentRequest) (models.Shipment, error) {
+	var shipment models.Shipment
+	session, err := shipmentCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return shipment, wrapDriverError(ErrCantUpdateShipment, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		releaseLabel(ctx, &shipment)
+		var order models.Order
+		err := orderCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: orderId}}).Decode(&order)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindOrder, err)
+		}
+		status := order.CurrentStatus()
+		if status != models.OrderPaid && status != models.OrderProcessing {
+			return nil, ErrOrderNotShippable
+		}
+
+		ordered, names := orderedQuantities(order)
+		shipments, err := orderShipments(sessCtx, shipmentCollection, orderId)
+		if err != nil {
+			return nil, err
+		}
+		shipper, err := shipmentCarrier(order, request.Carrier)
+		if err != nil {
+			return nil, err
+		}
+		shipped := shippedQuantities(shipments, models.ShipmentStatus.Active)
+		lines := make([]models.ShipmentLine, 0, len(request.Lines))
+		if len(request.Lines) == 0 {
+			for _, item := range order.OrderCart {
+				if left := ordered[item.ProductId] - shipped[item.ProductId]; left > 0 {
+					lines = append(lines, models.ShipmentLine{ProductId: item.ProductId, ProductName: item.ProductName, Quantity: left})
+					shipped[item.ProductId] = ordered[item.ProductId]
+				}
+			}
+			if len(lines) == 0 {
+				return nil, ErrNothingToShip
+			}
+		}
+		for _, line := range request.Lines {
+			shipped[line.ProductId] += line.Quantity
+			if shipped[line.ProductId] > ordered[line.ProductId] {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidShipmentQuantity, line.ProductId.Hex())
+			}
+			line.ProductName = names[line.ProductId]
+			lines = append(lines, line)
+		}
+
+		label, err := shipper.CreateLabel(ctx, shipmentParcel(order, lines, request.TrackingNumber))
+		if err != nil {
+			log.Println(err)
+			return nil, fmt.Errorf("%w: %v", ErrCarrierUnavailable, err)
+		}
+		shipment.Carrier, shipment.TrackingNumber = shipper.Name(), label.TrackingNumber
+
+		if status == models.OrderPaid {
+			if err = transitionOrder(sessCtx, productCollection, orderCollection, paymentCollection, &order, models.OrderProcessing, "Packing a shipment"); err != nil {
+				return nil, err
+			}
+		}
+		now := time.Now()
+		shipment = models.Shipment{
+			ShipmentId:     primitive.NewObjectID(),
+			OrderId:        orderId,
+			UserId:         order.UserId,
+			Lines:          lines,
+			Carrier:        shipper.Name(),
+			TrackingNumber: label.TrackingNumber,
+			LabelRef:       label.LabelRef,
+			Status:         models.ShipmentPacking,
+			Events:         []models.TrackingEvent{{Status: models.ShipmentPacking, Description: "Shipment created", OccurredAt: now}},
+			CreatedAt:      now,
+		}
+		if _, err = shipmentCollection.InsertOne(sessCtx, shipment); err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantUpdateShipment, err)
+		}
+		update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "shipments_created", Value: 1}}}}
+		if _, err = orderCollection.UpdateOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: orderId}}, update); err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantUpdateOrder, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		releaseLabel(ctx, &shipment)
+	}
+	return shipment, err
+}
+
+// RecordTrackingEvent adds an event reported for the shipment to its tracking
+// history and moves the shipment to the event's status. The order follows its
+// shipments: it is shipped once all of its items are on their way and
+// delivered once all of them have arrived.
+func RecordTrackingEvent(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, shipmentCollection *mongo.Collection, shipmentId primitive.ObjectID, request models.TrackingEventRequest) (models.Shipment, error) {
+	var shipment models.Shipment
+	session, err := shipmentCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return shipment, wrapDriverError(ErrCantUpdateShipment, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		err := shipmentCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: shipmentId}}).Decode(&shipment)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindShipment, err)
+		}
+		if err = trackShipment(sessCtx, shipmentCollection, &shipment, request); err != nil {
+			return nil, err
+		}
+		return nil, followShipments(sessCtx, productCollection, orderCollection, paymentCollection, shipmentCollection, shipment.OrderId)
+	})
+	if err == nil {
+		settleOrderPayment(ctx, paymentCollection, shipment.OrderId)
+	}
+	return shipment, err
+}
+
+// trackShipment records the event on shipment within the caller's transaction.
+func trackShipment(ctx context.Context, shipmentCollection *mongo.Collection, shipment *models.Shipment, request models.TrackingEventRequest) error {
+	if shipment.Status.Final() {
+		return ErrShipmentClosed
+	}
+	event := models.TrackingEvent{
+		Status:      request.Status,
+		Description: request.Description,
+		Location:    request.Location,
+		OccurredAt:  time.Now(),
+	}
+	if request.OccurredAt != nil {
+		event.OccurredAt = *request.OccurredAt
+	}
+	fields := bson.D{primitive.E{Key: "status", Value: event.Status}}
+	if shipment.ShippedAt == nil && event.Status.Dispatched() {
+		shipment.ShippedAt = &event.OccurredAt
+		fields = append(fields, primitive.E{Key: "shipped_at", Value: event.OccurredAt})
+	}
+	if event.Status == models.ShipmentDelivered {
+		shipment.DeliveredAt = &event.OccurredAt
+		fields = append(fields, primitive.E{Key: "delivered_at", Value: event.OccurredAt})
+	}
+	shipment.Status = event.Status
+	shipment.Events = append(shipment.Events, event)
+
+	filter := bson.D{primitive.E{Key: "_id", Value: shipment.ShipmentId}}
+	update := bson.D{
+		{Key: "$set", Value: fields},
+		{Key: "$push", Value: bson.D{primitive.E{Key: "events", Value: event}}},
+	}
+	if _, err := shipmentCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateShipment, err)
+	}
+	return nil
+}
+
+// followShipments moves the order along once its shipments have all been
+// dispatched or have all arrived.
+func followShipments(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, shipmentCollection *mongo.Collection, orderId primitive.ObjectID) error {
+	var order models.Order
+	err := orderCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: orderId}}).Decode(&order)
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantFindOrder, err)
+	}
+	shipments, err := orderShipments(ctx, shipmentCollection, orderId)
+	if err != nil {
+		return err
+	}
+	ordered, _ := orderedQuantities(order)
+	covers := func(shipped map[primitive.ObjectID]uint64) bool {
+		for productId, quantity := range ordered {
+			if shipped[productId] < quantity {
+				return false
+			}
+		}
+		return true
+	}
+
+	dispatched := shippedQuantities(shipments, models.ShipmentStatus.Dispatched)
+	if order.CurrentStatus() == models.OrderProcessing && covers(dispatched) {
+		if err = transitionOrder(ctx, productCollection, orderCollection, paymentCollection, &order, models.OrderShipped, "All items shipped"); err != nil {
+			return err
+		}
+	}
+	delivered := shippedQuantities(shipments, func(s models.ShipmentStatus) bool { return s == models.ShipmentDelivered })
+	if order.CurrentStatus() == models.OrderShipped && covers(delivered) {
+		return transitionOrder(ctx, productCollection, orderCollection, paymentCollection, &order, models.OrderDelivered, "All shipments delivered")
+	}
+	return nil
+}
+
+// SyncShipment asks the shipment's carrier for its tracking and records the
+// events that are newer than the ones the shipment has.
+func SyncShipment(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, shipmentCollection *mongo.Collection, shipmentId primitive.ObjectID) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := shipmentCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: shipmentId}}).Decode(&shipment)
+	if err != nil {
+		log.Println(err)
+		return shipment, wrapDriverError(ErrCantFindShipment, err)
+	}
+	shipper, err := carrier.Get(shipment.Carrier)
+	if err != nil {
+		return shipment, err
+	}
+	events, err := shipper.Track(ctx, shipment.TrackingNumber)
+	if err != nil {
+		log.Println(err)
+		return shipment, fmt.Errorf("%w: %v", ErrCarrierUnavailable, err)
+	}
+
+	session, err := shipmentCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return shipment, wrapDriverError(ErrCantUpdateShipment, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		err := shipmentCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: shipmentId}}).Decode(&shipment)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindShipment, err)
+		}
+		var latest time.Time
+		for _, event := range shipment.Events {
+			if event.OccurredAt.After(latest) {
+				latest = event.OccurredAt
+			}
+		}
+		for _, event := range events {
+			if !event.OccurredAt.After(latest) || shipment.Status.Final() {
+				continue
+			}
+			occurredAt := event.OccurredAt
+			request := models.TrackingEventRequest{Status: event.Status, Description: event.Description, Location: event.Location, OccurredAt: &occurredAt}
+			if err = trackShipment(sessCtx, shipmentCollection, &shipment, request); err != nil {
+				return nil, err
+			}
+		}
+		return nil, followShipments(sessCtx, productCollection, orderCollection, paymentCollection, shipmentCollection, shipment.OrderId)
+	})
+	if err == nil {
+		settleOrderPayment(ctx, paymentCollection, shipment.OrderId)
+	}
+	return shipment, err
+}
+
+// CancelShipment calls off a shipment that is still being packed, so that its
+// items can be shipped in another.
+func CancelShipment(ctx context.Context, shipmentCollection *mongo.Collection, shipmentId primitive.ObjectID, reason string) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := shipmentCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: shipmentId}}).Decode(&shipment)
+	if err != nil {
+		log.Println(err)
+		return shipment, wrapDriverError(ErrCantFindShipment, err)
+	}
+	if shipment.Status != models.ShipmentPacking {
+		return shipment, ErrShipmentNotCancellable
+	}
+	shipper, err := carrier.Get(shipment.Carrier)
+	if err != nil {
+		return shipment, err
+	}
+	if err = shipper.Cancel(ctx, shipment.TrackingNumber); err != nil {
+		log.Println(err)
+		return shipment, fmt.Errorf("%w: %v", ErrShipmentNotCancellable, err)
+	}
+	if reason == "" {
+		reason = "Shipment cancelled"
+	}
+	err = trackShipment(ctx, shipmentCollection, &shipment, models.TrackingEventRequest{Status: models.ShipmentCancelled, Description: reason})
+	return shipment, err
+}
+
+func GetShipment(ctx context.Context, shipmentCollection *mongo.Collection, shipmentId primitive.ObjectID) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := shipmentCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: shipmentId}}).Decode(&shipment)
+	if err != nil {
+		log.Println(err)
+		return shipment, wrapDriverError(ErrCantFindShipment, err)
+	}
+	return shipment, nil
+}
+
+func ListOrderShipments(ctx context.Context, shipmentCollection *mongo.Collection, orderId primitive.ObjectID) ([]models.Shipment, error) {
+	return orderShipments(ctx, shipmentCollection, orderId)
+}
+
+// ListUserShipments returns the shipments of one of the user's orders.
+func ListUserShipments(ctx context.Context, orderCollection *mongo.Collection, shipmentCollection *mongo.Collection, userId string, orderId primitive.ObjectID) ([]models.Shipment, error) {
+	if _, err := GetUserOrder(ctx, orderCollection, userId, orderId); err != nil {
+		return nil, err
+	}
+	return orderShipments(ctx, shipmentCollection, orderId)
+}
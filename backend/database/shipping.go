@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/divisions"
+	"backend/models"
+	"backend/pricing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindShippingZone   = errors.New("cannot find the shipping zone")
+	ErrCantUpdateShippingZone = errors.New("cannot update the shipping zone")
+)
+
+func ListShippingZones(ctx context.Context, shippingZoneCollection *mongo.Collection) ([]models.ShippingZone, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "name", Value: 1}})
+	cursor, err := shippingZoneCollection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindShippingZone, err)
+	}
+	zones := make([]models.ShippingZone, 0)
+	if err = cursor.All(ctx, &zones); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindShippingZone, err)
+	}
+	return zones, nil
+}
+
+// shippingZone builds the zone of the request with its regions under the
+// official names addresses are given, so that the zone matches them.
+func shippingZone(zoneId primitive.ObjectID, request models.ShippingZoneRequest) (models.ShippingZone, error) {
+	regions := make([]models.ShippingRegion, 0, len(request.Regions))
+	for _, region := range request.Regions {
+		var err error
+		if region.City, region.District, err = divisions.NormalizeRegion(region.City, region.District); err != nil {
+			return models.ShippingZone{}, err
+		}
+		regions = append(regions, region)
+	}
+	return models.ShippingZone{ZoneId: zoneId, Name: request.Name, Regions: regions, Rates: request.Rates}, nil
+}
+
+func CreateShippingZone(ctx context.Context, shippingZoneCollection *mongo.Collection, request models.ShippingZoneRequest) (models.ShippingZone, error) {
+	zone, err := shippingZone(primitive.NewObjectID(), request)
+	if err != nil {
+		return zone, err
+	}
+	if _, err = shippingZoneCollection.InsertOne(ctx, zone); err != nil {
+		log.Println(err)
+		return zone, wrapDriverError(ErrCantUpdateShippingZone, err)
+	}
+	return zone, nil
+}
+
+func UpdateShippingZone(ctx context.Context, shippingZoneCollection *mongo.Collection, zoneId primitive.ObjectID, request models.ShippingZoneRequest) (models.ShippingZone, error) {
+	zone, err := shippingZone(zoneId, request)
+	if err != nil {
+		return zone, err
+	}
+	result, err := shippingZoneCollection.ReplaceOne(ctx, bson.D{primitive.E{Key: "_id", Value: zoneId}}, zone)
+	if err != nil {
+		log.Println(err)
+		return zone, wrapDriverError(ErrCantUpdateShippingZone, err)
+	}
+	if result.MatchedCount == 0 {
+		return zone, ErrCantFindShippingZone
+	}
+	return zone, nil
+}
+
+func DeleteShippingZone(ctx context.Context, shippingZoneCollection *mongo.Collection, zoneId primitive.ObjectID) error {
+	result, err := shippingZoneCollection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: zoneId}})
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateShippingZone, err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrCantFindShippingZone
+	}
+	return nil
+}
+
+// MigrateShippingZoneRegions renames the regions of the shipping zones saved
+// before regions were checked to the official names. Zones with a region that
+// is not a known one are logged and left as they are.
+func MigrateShippingZoneRegions(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	shippingZoneCollection := ShippingZoneData(client, "ShippingZones")
+	zones, err := ListShippingZones(ctx, shippingZoneCollection)
+	if err != nil {
+		return
+	}
+	for _, zone := range zones {
+		normalized, err := shippingZone(zone.ZoneId, models.ShippingZoneRequest{Name: zone.Name, Regions: zone.Regions, Rates: zone.Rates})
+		if err != nil {
+			log.Println(zone.ZoneId.Hex(), err)
+			continue
+		}
+		filter := bson.D{primitive.E{Key: "_id", Value: zone.ZoneId}}
+		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "regions", Value: normalized.Regions}}}}
+		if _, err = shippingZoneCollection.UpdateOne(ctx, filter, update); err != nil {
+			log.Println(zone.ZoneId.Hex(), err)
+		}
+	}
+}
+
+// QuoteCartShipping lists the delivery options for the user's cart shipped to
+// one of their saved addresses, the first one unless addressId is given.
+func QuoteCartShipping(ctx context.Context, productCollection *mongo.Collection, userCollection *mongo.Collection, pricer *CartPricer, userId string, addressId string) ([]models.ShippingQuote, error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, ErrUserIdIsNotValid
+	}
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&user)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrUserIdIsNotValid, err)
+	}
+	address, err := ShippingAddress(user, addressId)
+	if err != nil {
+		return nil, err
+	}
+	cart, _, err := ResolveCart(ctx, productCollection, user.UserCart)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart) == 0 {
+		return nil, ErrCartIsEmpty
+	}
+	priced, err := pricer.PriceCart(ctx, PriceRequest{UserId: userId, CouponCode: user.AppliedCoupon, Address: address}, cart)
+	if err != nil {
+		return nil, err
+	}
+	if errors.Is(priced.DeliveryErr, pricing.ErrNoShippingZone) {
+		return nil, priced.DeliveryErr
+	}
+	if priced.Quotes == nil {
+		priced.Quotes = make([]models.ShippingQuote, 0)
+	}
+	return priced.Quotes, nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrCantUseStoreCredit = errors.New("cannot use the store credit")
+	ErrStoreCreditChanged = errors.New("store credit changed while placing the order")
+)
+
+// storeCreditUsers is the collection of the users whose store credit orders
+// spend and refunds add to, kept in the database of the orders.
+func storeCreditUsers(orderCollection *mongo.Collection) *mongo.Collection {
+	return orderCollection.Database().Collection("Users")
+}
+
+// spendStoreCredit takes as much of amount as the user's store credit covers
+// off it and returns what it took. The credit is only taken while the user
+// still has it, so two orders cannot spend the same credit.
+func spendStoreCredit(ctx context.Context, userCollection *mongo.Collection, user models.User, amount uint64) (uint64, error) {
+	credit := user.StoreCredit
+	if credit > amount {
+		credit = amount
+	}
+	if credit == 0 {
+		return 0, nil
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: user.Id}, {Key: "store_credit", Value: bson.M{"$gte": credit}}}
+	update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "store_credit", Value: -int64(credit)}}}}
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return 0, wrapDriverError(ErrCantUseStoreCredit, err)
+	}
+	if result.MatchedCount == 0 {
+		return 0, ErrStoreCreditChanged
+	}
+	return credit, nil
+}
+
+// addStoreCredit adds amount to the store credit of the user.
+func addStoreCredit(ctx context.Context, userCollection *mongo.Collection, userId string, amount uint64) error {
+	usertId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: usertId}}
+	update := bson.D{{Key: "$inc", Value: bson.D{primitive.E{Key: "store_credit", Value: int64(amount)}}}}
+	if _, err = userCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantRefundStoreCredit, err)
+	}
+	return nil
+}
+
+// refundStoreCredit adds amount of the order back to the user's store credit
+// and records the refund on the order.
+func refundStoreCredit(ctx context.Context, orderCollection *mongo.Collection, userCollection *mongo.Collection, order models.Order, amount uint64, reference string) error {
+	if err := addStoreCredit(ctx, userCollection, order.UserId, amount); err != nil {
+		return err
+	}
+	return recordRefund(ctx, orderCollection, order.OrderId, models.Refund{
+		Amount:     amount,
+		Method:     models.RefundToStoreCredit,
+		Reference:  reference,
+		RefundedAt: time.Now(),
+	})
+}
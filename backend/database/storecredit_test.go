@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStoreCredit(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	users, products, orders := db.Collection("Users"), db.Collection("Products"), db.Collection("Orders")
+	payments, counters, shipments := db.Collection("Payments"), db.Collection("Counters"), db.Collection("Shipments")
+	pricer := NewCartPricer(db.Collection("Coupons"), db.Collection("Promotions"), db.Collection("TaxRates"), db.Collection("ShippingZones"), orders)
+
+	user := models.User{Id: primitive.NewObjectID(), StoreCredit: 150000}
+	user.UserId = user.Id.Hex()
+	if _, err := users.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{ProductId: primitive.NewObjectID(), ProductName: "Áo thun", Price: 100000}
+	if _, err := products.InsertOne(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	opts := models.CheckoutOptions{
+		Address:        &models.Address{House: "1", Street: "Phố A", City: "Hà Nội", District: "Quận Ba Đình"},
+		UseStoreCredit: true,
+	}
+	credit := func() uint64 {
+		var stored models.User
+		if err := users.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: user.Id}}).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+		return stored.StoreCredit
+	}
+
+	order, err := InstantBuyer(ctx, products, users, orders, payments, counters, pricer, product.ProductId, 1, user.UserId, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.StoreCreditUsed != 100000 || order.PaymentMethod.Method != models.PaymentStoreCredit || order.Status != models.OrderPaid {
+		t.Errorf("got %d paid with store credit by %s in status %s, want the whole order paid", order.StoreCreditUsed, order.PaymentMethod.Method, order.Status)
+	}
+	if got := credit(); got != 50000 {
+		t.Errorf("got %d store credit left, want 50000", got)
+	}
+
+	partly, err := InstantBuyer(ctx, products, users, orders, payments, counters, pricer, product.ProductId, 1, user.UserId, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partly.StoreCreditUsed != 50000 || partly.PaymentMethod.Method != models.PaymentCOD {
+		t.Errorf("got %d paid with store credit by %s, want 50000 and the rest on delivery", partly.StoreCreditUsed, partly.PaymentMethod.Method)
+	}
+	if intent, err := GetPaymentIntent(ctx, payments, partly.OrderId); err != nil || intent.Amount != 50000 {
+		t.Errorf("got a payment of %d (%v), want 50000", intent.Amount, err)
+	}
+
+	cancelled, err := CancelOrder(ctx, products, orders, payments, shipments, user.UserId, order.OrderId, "Changed my mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != models.OrderRefunded {
+		t.Errorf("got status %s, want %s", cancelled.Status, models.OrderRefunded)
+	}
+	if got := credit(); got != 100000 {
+		t.Errorf("got %d store credit after the cancellation, want 100000", got)
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/divisions"
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindTaxRate   = errors.New("cannot find the tax rate")
+	ErrCantUpdateTaxRate = errors.New("cannot update the tax rate")
+	ErrTaxRateExists     = errors.New("a tax rate for this class and region already exists")
+)
+
+// normalizeTaxRegion replaces the region of the request with the official
+// names addresses are given, so that the rate matches them.
+func normalizeTaxRegion(request *models.TaxRateRequest) error {
+	var err error
+	request.City, request.District, err = divisions.NormalizeRegion(request.City, request.District)
+	return err
+}
+
+func taxRateFields(request models.TaxRateRequest) bson.D {
+	return bson.D{
+		primitive.E{Key: "name", Value: request.Name},
+		{Key: "tax_class", Value: request.TaxClass},
+		{Key: "city", Value: request.City},
+		{Key: "district", Value: request.District},
+		{Key: "rate", Value: request.Rate},
+		{Key: "inclusive", Value: request.Inclusive},
+	}
+}
+
+func ListTaxRates(ctx context.Context, taxRateCollection *mongo.Collection) ([]models.TaxRate, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "tax_class", Value: 1}, {Key: "city", Value: 1}, {Key: "district", Value: 1}})
+	cursor, err := taxRateCollection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindTaxRate, err)
+	}
+	rates := make([]models.TaxRate, 0)
+	if err = cursor.All(ctx, &rates); err != nil {
+		log.Println(err)
+		return nil, wrapDriverError(ErrCantFindTaxRate, err)
+	}
+	return rates, nil
+}
+
+func CreateTaxRate(ctx context.Context, taxRateCollection *mongo.Collection, request models.TaxRateRequest) (models.TaxRate, error) {
+	if err := normalizeTaxRegion(&request); err != nil {
+		return models.TaxRate{}, err
+	}
+	rate := models.TaxRate{
+		RateId:    primitive.NewObjectID(),
+		Name:      request.Name,
+		TaxClass:  request.TaxClass,
+		City:      request.City,
+		District:  request.District,
+		Rate:      request.Rate,
+		Inclusive: request.Inclusive,
+	}
+	_, err := taxRateCollection.InsertOne(ctx, rate)
+	if mongo.IsDuplicateKeyError(err) {
+		return rate, ErrTaxRateExists
+	}
+	if err != nil {
+		log.Println(err)
+		return rate, wrapDriverError(ErrCantUpdateTaxRate, err)
+	}
+	return rate, nil
+}
+
+func UpdateTaxRate(ctx context.Context, taxRateCollection *mongo.Collection, rateId primitive.ObjectID, request models.TaxRateRequest) (models.TaxRate, error) {
+	var rate models.TaxRate
+	if err := normalizeTaxRegion(&request); err != nil {
+		return rate, err
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: rateId}}
+	update := bson.D{{Key: "$set", Value: taxRateFields(request)}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := taxRateCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&rate)
+	if mongo.IsDuplicateKeyError(err) {
+		return rate, ErrTaxRateExists
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return rate, ErrCantFindTaxRate
+	}
+	if err != nil {
+		log.Println(err)
+		return rate, wrapDriverError(ErrCantUpdateTaxRate, err)
+	}
+	return rate, nil
+}
+
+func DeleteTaxRate(ctx context.Context, taxRateCollection *mongo.Collection, rateId primitive.ObjectID) error {
+	result, err := taxRateCollection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: rateId}})
+	if err != nil {
+		log.Println(err)
+		return wrapDriverError(ErrCantUpdateTaxRate, err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrCantFindTaxRate
+	}
+	return nil
+}
+
+// MigrateTaxRateRegions renames the regions of the tax rates saved before
+// regions were checked to the official names. Rates whose region is not a
+// known one are logged and left as they are.
+func MigrateTaxRateRegions(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	taxRateCollection := TaxRateData(client, "TaxRates")
+	rates, err := ListTaxRates(ctx, taxRateCollection)
+	if err != nil {
+		return
+	}
+	for _, rate := range rates {
+		city, district, err := divisions.NormalizeRegion(rate.City, rate.District)
+		if err != nil {
+			log.Println(rate.RateId.Hex(), err)
+			continue
+		}
+		if city == rate.City && district == rate.District {
+			continue
+		}
+		filter := bson.D{primitive.E{Key: "_id", Value: rate.RateId}}
+		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "city", Value: city}, {Key: "district", Value: district}}}}
+		if _, err = taxRateCollection.UpdateOne(ctx, filter, update); err != nil {
+			log.Println(rate.RateId.Hex(), err)
+		}
+	}
+}
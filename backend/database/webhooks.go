@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/payment"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrDuplicateWebhookEvent  = errors.New("webhook event was already processed")
+	ErrCantRecordWebhookEvent = errors.New("cannot record the webhook event")
+)
+
+// WebhookEventTTL is how long processed webhook events are remembered. It is
+// well beyond how long providers keep redelivering an event.
+const WebhookEventTTL = 30 * 24 * time.Hour
+
+// ProcessPaymentEvent applies a verified provider event to its payment intent
+// and moves the order along: a confirmed capture marks the order paid, a failed
+// or voided payment cancels it, and refunds made at the provider are recorded.
+//
+// Each event is processed once. The event is recorded in the same transaction
+// as its effects, so a redelivered event returns ErrDuplicateWebhookEvent while
+// one that failed part way can be delivered again. Events that no longer apply
+// to the payment, such as a late capture of a refunded payment, change nothing.
+func ProcessPaymentEvent(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, webhookEventCollection *mongo.Collection, event models.PaymentEvent) (models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	session, err := paymentCollection.Database().Client().StartSession()
+	if err != nil {
+		log.Println(err)
+		return intent, wrapDriverError(ErrCantRecordWebhookEvent, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.D{primitive.E{Key: "provider", Value: event.Provider}, {Key: "provider_ref", Value: event.ProviderRef}}
+		err := paymentCollection.FindOne(sessCtx, filter).Decode(&intent)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindPayment, err)
+		}
+		record := models.WebhookEvent{
+			Key:        event.Provider + ":" + event.EventId,
+			Event:      event,
+			IntentId:   intent.IntentId,
+			ReceivedAt: time.Now(),
+		}
+		if _, err = webhookEventCollection.InsertOne(sessCtx, record); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, ErrDuplicateWebhookEvent
+			}
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantRecordWebhookEvent, err)
+		}
+
+		refunded := intent.RefundedAmount
+		if !payment.ApplyEvent(&intent, event) {
+			return nil, nil
+		}
+		if err = savePaymentIntent(sessCtx, paymentCollection, &intent); err != nil {
+			return nil, err
+		}
+
+		var order models.Order
+		err = orderCollection.FindOne(sessCtx, bson.D{primitive.E{Key: "_id", Value: intent.OrderId}}).Decode(&order)
+		if err != nil {
+			log.Println(err)
+			return nil, wrapDriverError(ErrCantFindOrder, err)
+		}
+		return nil, applyPaymentToOrder(sessCtx, productCollection, orderCollection, paymentCollection, &order, intent, intent.RefundedAmount-refunded, event.EventId)
+	})
+	if err == nil {
+		settleOrderPayment(ctx, paymentCollection, intent.OrderId)
+	}
+	return intent, err
+}
+
+// applyPaymentToOrder moves the order to match the payment intent after a
+// provider event changed it. refunded is the amount the event paid back.
+func applyPaymentToOrder(ctx context.Context, productCollection *mongo.Collection, orderCollection *mongo.Collection, paymentCollection *mongo.Collection, order *models.Order, intent models.PaymentIntent, refunded uint64, eventId string) error {
+	current := order.CurrentStatus()
+	switch intent.Status {
+	case models.PaymentCaptured:
+		if current == models.OrderPendingPayment {
+			return transitionOrder(ctx, productCollection, orderCollection, paymentCollection, order, models.OrderPaid, "Payment confirmed by the provider")
+		}
+	case models.PaymentFailed, models.PaymentVoided:
+		if current == models.OrderPendingPayment {
+			return transitionOrder(ctx, productCollection, orderCollection, paymentCollection, order, models.OrderCancelled, "Payment "+string(intent.Status)+" at the provider")
+		}
+	case models.PaymentPartiallyRefunded, models.PaymentRefunded:
+		err := recordRefund(ctx, orderCollection, order.OrderId, models.Refund{
+			Amount:     refunded,
+			Method:     models.RefundToOriginalPayment,
+			Reference:  eventId,
+			RefundedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if intent.Status == models.PaymentRefunded && current.CanTransitionTo(models.OrderRefunded) {
+			return transitionOrder(ctx, productCollection, orderCollection, paymentCollection, order, models.OrderRefunded, "Refunded at the provider")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package divisions holds the administrative divisions of Vietnam, provinces,
+// their districts and the districts' wards, with their official codes, and
+// checks addresses against them.
+//
+// The divisions are read from data/vietnam.json. A province listed without
+// districts, or a district without wards, has not been filled in yet, and
+// addresses in it keep the district or ward as written until it is.
+package divisions
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"backend/models"
+)
+
+var (
+	ErrUnknownProvince       = errors.New("city is not a province of Vietnam")
+	ErrDistrictNotInProvince = errors.New("district is not in the city")
+	ErrWardNotInDistrict     = errors.New("ward is not in the district")
+	ErrDivisionsNotListed    = errors.New("the districts or wards of this area are not listed yet, give them by name")
+	ErrRegionNeedsCity       = errors.New("a district must be given with its city")
+)
+
+// Division is a province, district or ward.
+type Division struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type ward struct {
+	Division
+}
+
+type district struct {
+	Division
+	Wards []ward `json:"wards"`
+}
+
+type province struct {
+	Division
+	Districts []district `json:"districts"`
+}
+
+//go:embed data/vietnam.json
+var data []byte
+
+var (
+	provinces []province
+	// districtsByCode indexes the districts of every province by their code.
+	districtsByCode = make(map[string]*district)
+)
+
+func init() {
+	if err := json.Unmarshal(data, &provinces); err != nil {
+		panic("divisions: " + err.Error())
+	}
+	for i := range provinces {
+		for j := range provinces[i].Districts {
+			district := &provinces[i].Districts[j]
+			districtsByCode[district.Code] = district
+		}
+	}
+}
+
+func Provinces() []Division {
+	list := make([]Division, 0, len(provinces))
+	for _, province := range provinces {
+		list = append(list, province.Division)
+	}
+	return list
+}
+
+func Districts(provinceCode string) ([]Division, error) {
+	province := findProvince(provinceCode, "")
+	if province == nil {
+		return nil, ErrUnknownProvince
+	}
+	list := make([]Division, 0, len(province.Districts))
+	for _, district := range province.Districts {
+		list = append(list, district.Division)
+	}
+	return list, nil
+}
+
+func Wards(districtCode string) ([]Division, error) {
+	district, ok := districtsByCode[districtCode]
+	if !ok {
+		return nil, ErrDistrictNotInProvince
+	}
+	list := make([]Division, 0, len(district.Wards))
+	for _, ward := range district.Wards {
+		list = append(list, ward.Division)
+	}
+	return list, nil
+}
+
+// Normalize checks that the ward of the address is in its district and the
+// district in its city, each given by code or by name, and fills in the
+// official names and codes. Districts and wards that are not listed are kept
+// as written.
+func Normalize(address models.Address) (models.Address, error) {
+	var err error
+	province := findProvince(address.CityCode, address.City)
+	if province == nil {
+		return address, ErrUnknownProvince
+	}
+	address.City, address.CityCode = province.Name, province.Code
+	if len(province.Districts) == 0 {
+		if address.District, err = unlisted(address.DistrictCode, address.District); err != nil {
+			return address, err
+		}
+		address.Ward, err = unlisted(address.WardCode, address.Ward)
+		address.DistrictCode, address.WardCode = "", ""
+		return address, err
+	}
+	found, err := findDistrict(province, address.DistrictCode, address.District)
+	if err != nil {
+		return address, err
+	}
+	address.District, address.DistrictCode = found.Name, found.Code
+	if address.Ward == "" && address.WardCode == "" {
+		return address, nil
+	}
+	if len(found.Wards) == 0 {
+		address.Ward, err = unlisted(address.WardCode, address.Ward)
+		address.WardCode = ""
+		return address, err
+	}
+
+	for _, ward := range found.Wards {
+		if matches(ward.Division, address.WardCode, address.Ward) {
+			address.Ward, address.WardCode = ward.Name, ward.Code
+			return address, nil
+		}
+	}
+	return address, ErrWardNotInDistrict
+}
+
+// NormalizeRegion checks a region of a tax rate or shipping zone, a city and
+// optionally one of its districts given by name, and returns their official
+// names, which are the names Normalize gives addresses. An empty region covers
+// the whole country. A district of a province whose districts are not listed is
+// kept as written.
+func NormalizeRegion(city string, district string) (string, string, error) {
+	if strings.TrimSpace(city) == "" {
+		if strings.TrimSpace(district) != "" {
+			return city, district, ErrRegionNeedsCity
+		}
+		return "", "", nil
+	}
+	province := findProvince("", city)
+	if province == nil {
+		return city, district, ErrUnknownProvince
+	}
+	if strings.TrimSpace(district) == "" {
+		return province.Name, "", nil
+	}
+	if len(province.Districts) == 0 {
+		return province.Name, strings.Join(strings.Fields(district), " "), nil
+	}
+	found, err := findDistrict(province, "", district)
+	if err != nil {
+		return province.Name, district, err
+	}
+	return province.Name, found.Name, nil
+}
+
+func findProvince(code string, name string) *province {
+	for i := range provinces {
+		if matches(provinces[i].Division, code, name) {
+			return &provinces[i]
+		}
+	}
+	return nil
+}
+
+func findDistrict(province *province, code string, name string) (*district, error) {
+	for i := range province.Districts {
+		if matches(province.Districts[i].Division, code, name) {
+			return &province.Districts[i], nil
+		}
+	}
+	return nil, ErrDistrictNotInProvince
+}
+
+// unlisted is the name of a district or ward that is not listed, as written.
+// Its code cannot be checked, so a code without a name is refused.
+func unlisted(code string, name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" && code != "" {
+		return "", ErrDivisionsNotListed
+	}
+	return name, nil
+}
+
+// matches reports whether the division has the code or, without a code, the
+// name. Names match regardless of case and of a leading division type, so
+// "Quận Ba Đình", "ba đình" and "Thành phố Hà Nội" are all found.
+func matches(division Division, code string, name string) bool {
+	if code != "" {
+		return division.Code == code
+	}
+	return name != "" && fold(division.Name) == fold(name)
+}
+
+// toneMarks writes the old style tone placement of oa, oe and uy, as in
+// "Hoà Bình", the way the data does, as in "Hòa Bình".
+var toneMarks = strings.NewReplacer(
+	"oà", "òa", "oá", "óa", "oả", "ỏa", "oã", "õa", "oạ", "ọa",
+	"oè", "òe", "oé", "óe", "oẻ", "ỏe", "oẽ", "õe", "oẹ", "ọe",
+	"uỳ", "ùy", "uý", "úy", "uỷ", "ủy", "uỹ", "ũy", "uỵ", "ụy",
+)
+
+var typePrefixes = []string{"thành phố ", "tp. ", "tp ", "tỉnh ", "quận ", "huyện ", "thị xã ", "thị trấn ", "phường ", "xã "}
+
+func fold(name string) string {
+	name = toneMarks.Replace(strings.ToLower(strings.Join(strings.Fields(name), " ")))
+	for _, prefix := range typePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package divisions
+
+import (
+	"errors"
+	"testing"
+
+	"backend/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  models.Address
+		city     string
+		district string
+		ward     string
+		err      error
+	}{
+		{"by name", models.Address{City: "thành phố hà nội", District: "ba đình", Ward: "phúc xá"}, "Hà Nội", "Quận Ba Đình", "Phường Phúc Xá", nil},
+		{"by code", models.Address{CityCode: "01", DistrictCode: "001"}, "Hà Nội", "Quận Ba Đình", "", nil},
+		{"unknown city", models.Address{City: "Atlantis", District: "Ba Đình"}, "", "", "", ErrUnknownProvince},
+		{"district of another city", models.Address{City: "Đà Nẵng", District: "Ba Đình"}, "", "", "", ErrDistrictNotInProvince},
+		{"ward of another district", models.Address{City: "Hà Nội", District: "Ba Đình", Ward: "Bến Nghé"}, "", "", "", ErrWardNotInDistrict},
+		{"districts not listed", models.Address{City: "Cà Mau", District: " Huyện  Năm Căn ", Ward: "Thị trấn Năm Căn"}, "Cà Mau", "Huyện Năm Căn", "Thị trấn Năm Căn", nil},
+		{"wards not listed", models.Address{City: "Hà Nội", District: "Tây Hồ", Ward: "Phường  Quảng An"}, "Hà Nội", "Quận Tây Hồ", "Phường Quảng An", nil},
+		{"district code not listed", models.Address{City: "Cà Mau", DistrictCode: "973"}, "", "", "", ErrDivisionsNotListed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address, err := Normalize(test.address)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err == nil && (address.City != test.city || address.District != test.district || address.Ward != test.ward) {
+				t.Errorf("got %q %q %q, want %q %q %q", address.City, address.District, address.Ward, test.city, test.district, test.ward)
+			}
+		})
+	}
+}
+
+func TestNormalizeRegion(t *testing.T) {
+	tests := []struct {
+		name           string
+		city, district string
+		wantCity       string
+		wantDistrict   string
+		err            error
+	}{
+		{"whole country", "", "", "", "", nil},
+		{"city", "tp hà nội", "", "Hà Nội", "", nil},
+		{"district", "Hà Nội", "quận ba đình", "Hà Nội", "Quận Ba Đình", nil},
+		{"city whose districts are not listed", "Cà Mau", "", "Cà Mau", "", nil},
+		{"district whose city lists none", "Cà Mau", " Huyện  Năm Căn", "Cà Mau", "Huyện Năm Căn", nil},
+		{"district without a city", "", "Ba Đình", "", "", ErrRegionNeedsCity},
+		{"unknown city", "Atlantis", "", "", "", ErrUnknownProvince},
+		{"district of another city", "Đà Nẵng", "Ba Đình", "", "", ErrDistrictNotInProvince},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			city, district, err := NormalizeRegion(test.city, test.district)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err == nil && (city != test.wantCity || district != test.wantDistrict) {
+				t.Errorf("got %q %q, want %q %q", city, district, test.wantCity, test.wantDistrict)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package documents lays out the printable documents of an order, its invoice
+// and its packing slip, as PDF.
+package documents
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"backend/models"
+	"backend/pdf"
+)
+
+const (
+	margin     = 40.0
+	lineHeight = 14.0
+)
+
+var storeName = storeNameOrDefault(os.Getenv("STORE_NAME"))
+
+func storeNameOrDefault(name string) string {
+	if name == "" {
+		return "Ecommerce"
+	}
+	return name
+}
+
+// OrderReference is how documents name the order, also encoded in their
+// barcode: its order number, or its id for orders placed before numbering.
+func OrderReference(order models.Order) string {
+	if order.OrderNumber != "" {
+		return order.OrderNumber
+	}
+	return order.OrderId.Hex()
+}
+
+// writer fills pages from the top down, starting a new page with the
+// document's heading when one is full.
+type writer struct {
+	doc     *pdf.Document
+	page    *pdf.Page
+	y       float64
+	heading func(w *writer)
+}
+
+func newWriter(heading func(w *writer)) *writer {
+	w := &writer{doc: pdf.New(), heading: heading}
+	w.newPage()
+	return w
+}
+
+func (w *writer) newPage() {
+	w.page = w.doc.AddPage()
+	w.y = pdf.PageHeight - margin
+	w.heading(w)
+}
+
+// next moves down by lines, starting a new page when they do not fit.
+func (w *writer) next(lines float64) {
+	if w.y-lines*lineHeight < margin {
+		w.newPage()
+	}
+	w.y -= lines * lineHeight
+}
+
+func (w *writer) rule() {
+	w.page.Line(margin, w.y-4, pdf.PageWidth-margin, w.y-4, 0.5)
+}
+
+// header writes the title, the store and the order reference with its barcode.
+func (w *writer) header(title string, order models.Order) {
+	w.page.Text(margin, w.y-18, 18, true, title)
+	w.page.Text(margin, w.y-34, 10, false, storeName)
+	reference := OrderReference(order)
+	x := pdf.PageWidth - margin - barcodeWidth(reference, 0.8)
+	if _, err := w.page.Barcode(x, w.y-36, 0.8, 30, reference); err != nil {
+		x = pdf.PageWidth - margin - pdf.TextWidth(reference, 9)
+	}
+	w.page.Text(x, w.y-48, 9, false, reference)
+	w.y -= 64
+}
+
+func barcodeWidth(data string, module float64) float64 {
+	widths, err := pdf.Code128(data)
+	if err != nil {
+		return 0
+	}
+	total := 0
+	for _, width := range widths {
+		total += width
+	}
+	return float64(total) * module
+}
+
+func addressLines(address models.Address) []string {
+	lines := make([]string, 0, 4)
+	if address.RecipientName != "" {
+		lines = append(lines, address.RecipientName)
+	}
+	if address.Phone != "" {
+		lines = append(lines, address.Phone)
+	}
+	lines = append(lines, strings.TrimSpace(address.House+" "+address.Street))
+	parts := make([]string, 0, 3)
+	for _, part := range []string{address.Ward, address.District, address.City} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return append(lines, strings.Join(parts, ", "))
+}
+
+// money formats an amount of VND with dots between the thousands.
+func money(amount uint64) string {
+	digits := strconv.FormatUint(amount, 10)
+	var out strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte('.')
+		}
+		out.WriteRune(digit)
+	}
+	return out.String()
+}
+
+// truncate shortens s to fit width at size.
+func truncate(s string, size float64, width float64) string {
+	if pdf.TextWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.TextWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+func orderedAt(order models.Order) string {
+	return fmt.Sprintf("Order date: %s", order.OrderedAt.Format("02/01/2006"))
+}
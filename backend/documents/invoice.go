@@ -0,0 +1,117 @@
+package documents
+
+import (
+	"fmt"
+	"strings"
+
+	"backend/models"
+	"backend/pdf"
+)
+
+// Right edges of the columns of the invoice lines.
+const (
+	productColumn   = margin
+	quantityColumn  = 320.0
+	unitPriceColumn = 390.0
+	discountColumn  = 450.0
+	taxColumn       = 505.0
+	amountColumn    = pdf.PageWidth - margin
+)
+
+// Invoice lays out the invoice of order, whose prices are in breakdown.
+func Invoice(order models.Order, breakdown models.PriceBreakdown) []byte {
+	w := newWriter(func(w *writer) {
+		w.header("INVOICE", order)
+	})
+
+	w.page.Text(margin, w.y, 10, false, orderedAt(order))
+	w.page.Text(margin, w.y-lineHeight, 10, false, "Payment: "+order.PaymentMethod.Method)
+	w.next(2.5)
+
+	billing := order.ShippingAddress
+	if order.BillingAddress != nil {
+		billing = *order.BillingAddress
+	}
+	w.page.Text(margin, w.y, 10, true, "Bill to")
+	w.page.Text(300, w.y, 10, true, "Ship to")
+	billingLines, shippingLines := addressLines(billing), addressLines(order.ShippingAddress)
+	for i := 0; i < len(billingLines) || i < len(shippingLines); i++ {
+		w.next(1)
+		if i < len(billingLines) {
+			w.page.Text(margin, w.y, 10, false, truncate(billingLines[i], 10, 240))
+		}
+		if i < len(shippingLines) {
+			w.page.Text(300, w.y, 10, false, truncate(shippingLines[i], 10, 255))
+		}
+	}
+	w.next(2)
+
+	lineHeader := func() {
+		w.page.Text(productColumn, w.y, 9, true, "Product")
+		w.page.TextRight(quantityColumn, w.y, 9, true, "Qty")
+		w.page.TextRight(unitPriceColumn, w.y, 9, true, "Unit price")
+		w.page.TextRight(discountColumn, w.y, 9, true, "Discount")
+		w.page.TextRight(taxColumn, w.y, 9, true, "Tax")
+		w.page.TextRight(amountColumn, w.y, 9, true, "Amount")
+		w.rule()
+	}
+	lineHeader()
+	for _, line := range breakdown.Lines {
+		page := w.page
+		w.next(1.2)
+		if w.page != page {
+			lineHeader()
+			w.next(1.2)
+		}
+		w.page.Text(productColumn, w.y, 9, false, truncate(line.ProductName, 9, quantityColumn-productColumn-40))
+		w.page.TextRight(quantityColumn, w.y, 9, false, fmt.Sprint(line.Quantity))
+		w.page.TextRight(unitPriceColumn, w.y, 9, false, money(line.UnitPrice))
+		w.page.TextRight(discountColumn, w.y, 9, false, money(line.Discount))
+		tax := money(line.Tax)
+		if line.TaxRate > 0 {
+			tax = fmt.Sprintf("%s (%s)", tax, percent(line.TaxRate))
+		}
+		w.page.TextRight(taxColumn, w.y, 9, false, tax)
+		w.page.TextRight(amountColumn, w.y, 9, false, money(line.LineTotal))
+	}
+	w.rule()
+	w.next(1)
+
+	total := func(label string, amount string, bold bool) {
+		w.next(1.2)
+		w.page.Text(unitPriceColumn-60, w.y, 10, bold, label)
+		w.page.TextRight(amountColumn, w.y, 10, bold, amount)
+	}
+	total("Subtotal", money(breakdown.Subtotal), false)
+	if breakdown.Discount > 0 {
+		total("Discount", "-"+money(breakdown.Discount), false)
+		if breakdown.CouponCode != "" {
+			total("  incl. coupon "+breakdown.CouponCode, "-"+money(breakdown.CouponDiscount), false)
+		}
+	}
+	shipping := "Shipping"
+	if breakdown.Delivery != nil {
+		shipping = "Shipping (" + breakdown.Delivery.Option + ")"
+	}
+	total(shipping, money(breakdown.Shipping), false)
+	if breakdown.Tax > breakdown.TaxIncluded {
+		total("Tax", money(breakdown.Tax-breakdown.TaxIncluded), false)
+	}
+	if breakdown.TaxIncluded > 0 {
+		total("Tax included in prices", money(breakdown.TaxIncluded), false)
+	}
+	total("Total (VND)", money(breakdown.Total), true)
+	if order.StoreCreditUsed > 0 {
+		total("Paid with store credit", "-"+money(order.StoreCreditUsed), false)
+		total("Amount due (VND)", money(order.Price-order.StoreCreditUsed), true)
+	}
+	return w.doc.Bytes()
+}
+
+// percent formats a rate in basis points, such as 525 as 5.25%.
+func percent(basisPoints uint64) string {
+	if basisPoints%100 == 0 {
+		return fmt.Sprintf("%d%%", basisPoints/100)
+	}
+	return strings.TrimRight(fmt.Sprintf("%d.%02d", basisPoints/100, basisPoints%100), "0") + "%"
+}
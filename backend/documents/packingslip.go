@@ -0,0 +1,64 @@
+package documents
+
+import (
+	"fmt"
+
+	"backend/models"
+	"backend/pdf"
+)
+
+// PackingSlip lays out the slip the warehouse packs lines of order by. Without
+// lines, every line of the order is packed.
+func PackingSlip(order models.Order, lines []models.ShipmentLine) []byte {
+	if len(lines) == 0 {
+		for _, item := range order.OrderCart {
+			lines = append(lines, models.ShipmentLine{ProductId: item.ProductId, ProductName: item.ProductName, Quantity: item.CartQuantity()})
+		}
+	}
+	w := newWriter(func(w *writer) {
+		w.header("PACKING SLIP", order)
+	})
+
+	w.page.Text(margin, w.y, 10, false, orderedAt(order))
+	if order.Pricing.Delivery != nil {
+		w.page.Text(margin, w.y-lineHeight, 10, false, "Delivery: "+order.Pricing.Delivery.Option)
+	}
+	w.next(2.5)
+
+	w.page.Text(margin, w.y, 10, true, "Ship to")
+	for _, line := range addressLines(order.ShippingAddress) {
+		w.next(1)
+		w.page.Text(margin, w.y, 11, false, line)
+	}
+	w.next(2)
+
+	lineHeader := func() {
+		w.page.Text(margin, w.y, 9, true, "Product")
+		w.page.Text(380, w.y, 9, true, "Product id")
+		w.page.TextRight(pdf.PageWidth-margin-30, w.y, 9, true, "Qty")
+		w.rule()
+	}
+	lineHeader()
+	var units uint64
+	for _, line := range lines {
+		page := w.page
+		w.next(1.4)
+		if w.page != page {
+			lineHeader()
+			w.next(1.4)
+		}
+		w.page.Text(margin, w.y, 10, false, truncate(line.ProductName, 10, 330))
+		w.page.Text(380, w.y, 7, false, line.ProductId.Hex())
+		w.page.TextRight(pdf.PageWidth-margin-30, w.y, 10, true, fmt.Sprint(line.Quantity))
+		// A box to tick once the line is packed.
+		w.page.Line(pdf.PageWidth-margin-12, w.y-2, pdf.PageWidth-margin, w.y-2, 0.5)
+		w.page.Line(pdf.PageWidth-margin-12, w.y+8, pdf.PageWidth-margin, w.y+8, 0.5)
+		w.page.Line(pdf.PageWidth-margin-12, w.y-2, pdf.PageWidth-margin-12, w.y+8, 0.5)
+		w.page.Line(pdf.PageWidth-margin, w.y-2, pdf.PageWidth-margin, w.y+8, 0.5)
+		units += line.Quantity
+	}
+	w.rule()
+	w.next(1.5)
+	w.page.TextRight(pdf.PageWidth-margin-30, w.y, 10, true, fmt.Sprintf("%d items", units))
+	return w.doc.Bytes()
+}
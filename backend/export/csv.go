@@ -0,0 +1,66 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// utf8BOM starts CSV files so that spreadsheet programs read them as UTF-8,
+// keeping Vietnamese names intact.
+const utf8BOM = "\ufeff"
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (w *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, 0, len(values))
+	for _, value := range values {
+		record = append(record, csvValue(value))
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// formulaPrefixes start cells that spreadsheet programs run as formulas.
+const formulaPrefixes = "=+-@\t\r"
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		// Names and notes come from users, so they are kept from running as
+		// formulas by a leading quote, which spreadsheets do not show.
+		if v != "" && strings.ContainsRune(formulaPrefixes, rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(timeLayout)
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+package export
+
+import "testing"
+
+func TestCSVValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"Áo thun", "Áo thun"},
+		{"", ""},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+84 912 345 678", "'+84 912 345 678"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{uint64(150000), "150000"},
+		{int64(-5), "-5"},
+	}
+	for _, test := range tests {
+		if got := csvValue(test.value); got != test.want {
+			t.Errorf("csvValue(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
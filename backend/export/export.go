@@ -0,0 +1,192 @@
+// Package export writes orders out as spreadsheets, one row per order or per
+// order line, in CSV or XLSX.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"backend/models"
+)
+
+var (
+	ErrUnknownFormat  = errors.New("export format is not supported")
+	ErrUnknownDataset = errors.New("export dataset is not supported")
+	ErrUnknownColumn  = errors.New("export column is not supported")
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+const (
+	// DatasetOrders has a row for every order.
+	DatasetOrders = "orders"
+	// DatasetLines has a row for every line of every order.
+	DatasetLines = "lines"
+)
+
+// Writer writes the rows of a spreadsheet. Values are strings, unsigned or
+// signed integers, booleans or times. Close finishes the file but leaves the
+// underlying writer open.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewWriter starts a spreadsheet in format on w.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ContentType is the media type of files in format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Row is what one row is made of: an order and, in the lines dataset, one of
+// its lines.
+type Row struct {
+	Order models.Order
+	Line  models.OrderLine
+}
+
+// Column is one column of a dataset and how its value is taken from a row.
+type Column struct {
+	Name  string
+	Value func(row Row) interface{}
+}
+
+var orderColumns = []Column{
+	{"order_number", func(r Row) interface{} { return r.Order.OrderNumber }},
+	{"order_id", func(r Row) interface{} { return r.Order.OrderId.Hex() }},
+	{"ordered_at", func(r Row) interface{} { return r.Order.OrderedAt }},
+	{"status", func(r Row) interface{} { return string(r.Order.CurrentStatus()) }},
+	{"customer_id", func(r Row) interface{} { return r.Order.UserId }},
+	{"payment_method", func(r Row) interface{} { return paymentMethod(r.Order.PaymentMethod) }},
+	{"subtotal", func(r Row) interface{} { return r.Order.Pricing.Subtotal }},
+	{"discount", func(r Row) interface{} { return r.Order.Pricing.Discount }},
+	{"coupon_code", func(r Row) interface{} { return r.Order.Pricing.CouponCode }},
+	{"shipping", func(r Row) interface{} { return r.Order.Pricing.Shipping }},
+	{"tax", func(r Row) interface{} { return r.Order.Pricing.Tax }},
+	{"tax_included", func(r Row) interface{} { return r.Order.Pricing.TaxIncluded }},
+	{"total", func(r Row) interface{} { return r.Order.Price }},
+	{"refunded", func(r Row) interface{} { return refunded(r.Order) }},
+	{"city", func(r Row) interface{} { return r.Order.ShippingAddress.City }},
+	{"district", func(r Row) interface{} { return r.Order.ShippingAddress.District }},
+}
+
+var lineColumns = []Column{
+	{"order_number", func(r Row) interface{} { return r.Order.OrderNumber }},
+	{"order_id", func(r Row) interface{} { return r.Order.OrderId.Hex() }},
+	{"ordered_at", func(r Row) interface{} { return r.Order.OrderedAt }},
+	{"status", func(r Row) interface{} { return string(r.Order.CurrentStatus()) }},
+	{"product_id", func(r Row) interface{} { return r.Line.ProductId.Hex() }},
+	{"product_name", func(r Row) interface{} { return r.Line.ProductName }},
+	{"quantity", func(r Row) interface{} { return r.Line.Quantity }},
+	{"unit_price", func(r Row) interface{} { return r.Line.UnitPrice }},
+	{"line_total", func(r Row) interface{} { return r.Line.LineTotal }},
+	{"discount", func(r Row) interface{} { return r.Line.Discount }},
+	{"tax_class", func(r Row) interface{} { return r.Line.TaxClass }},
+	{"tax_rate", func(r Row) interface{} { return r.Line.TaxRate }},
+	{"tax_inclusive", func(r Row) interface{} { return r.Line.TaxInclusive }},
+	{"tax", func(r Row) interface{} { return r.Line.Tax }},
+}
+
+func datasetColumns(dataset string) ([]Column, error) {
+	switch dataset {
+	case DatasetOrders:
+		return orderColumns, nil
+	case DatasetLines:
+		return lineColumns, nil
+	}
+	return nil, ErrUnknownDataset
+}
+
+// Columns picks the columns of dataset by name, in the order given. Without
+// names every column is picked.
+func Columns(dataset string, names []string) ([]Column, error) {
+	all, err := datasetColumns(dataset)
+	if err != nil || len(names) == 0 {
+		return all, err
+	}
+	picked := make([]Column, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, column := range all {
+			if column.Name == strings.TrimSpace(name) {
+				picked = append(picked, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, name)
+		}
+	}
+	return picked, nil
+}
+
+// ColumnNames lists the columns of dataset.
+func ColumnNames(dataset string) ([]string, error) {
+	columns, err := datasetColumns(dataset)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	return names, nil
+}
+
+// Header is the first row of a spreadsheet of columns.
+func Header(columns []Column) []interface{} {
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, column.Name)
+	}
+	return values
+}
+
+// Values is the row of columns for row.
+func Values(columns []Column, row Row) []interface{} {
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, column.Value(row))
+	}
+	return values
+}
+
+// paymentMethod names how the order was paid, counting orders placed before
+// methods were recorded as cash on delivery when they were.
+func paymentMethod(payment models.Payment) string {
+	if payment.Method == "" && payment.COD {
+		return models.PaymentCOD
+	}
+	return payment.Method
+}
+
+func refunded(order models.Order) uint64 {
+	var amount uint64
+	for _, refund := range order.Refunds {
+		amount += refund.Amount
+	}
+	return amount
+}
+
+// timeLayout is how times are written where the format has no time type.
+const timeLayout = time.RFC3339
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"backend/database"
 	"backend/routes"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,14 @@ func main() {
 		port = "8000"
 	}
 
+	database.CreateIndexes(database.Client)
+	database.MigrateEmbeddedOrders(database.Client)
+	database.MigrateCouponRedemptions(database.Client)
+	database.MigrateTaxRateRegions(database.Client)
+	database.MigrateShippingZoneRegions(database.Client)
+	database.FailInterruptedExports(database.Client)
+	go database.RetryPaymentOperations(database.Client)
+
 	router := gin.New()
 
 	router.Use(gin.Logger())
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes the route safe to retry when the client sends an
+// Idempotency-Key header. The first successful response for a key is stored and
+// returned again for every retry of the same request, so the handler succeeds
+// at most once.
+// It must run after Authorization, as keys are scoped to the calling user.
+func Idempotency(idempotencyCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		idempotencyKey := c.Request.Header.Get("Idempotency-Key")
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Status = "Failed"
+			response.Code = http.StatusBadRequest
+			response.Msg = "Invalid request body"
+			c.IndentedJSON(http.StatusBadRequest, response)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := c.GetString("uid") + ":" + idempotencyKey
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "?" + c.Request.URL.RawQuery + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		record, lease, err := database.BeginIdempotentRequest(ctx, idempotencyCollection, key, fingerprint)
+		cancel()
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, database.ErrIdempotencyKeyReused) {
+				code = http.StatusUnprocessableEntity
+			} else if errors.Is(err, database.ErrIdempotencyKeyInProgress) {
+				code = http.StatusConflict
+			}
+			response.Status = "Failed"
+			response.Code = uint(code)
+			response.Msg = err.Error()
+			c.IndentedJSON(code, response)
+			c.Abort()
+			return
+		}
+		if record != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.ResponseCode, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		// A handler that panics changed nothing it did not roll back, so its
+		// key is released for the client to retry before the panic goes on.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				releaseIdempotencyKey(idempotencyCollection, key, lease)
+				panic(recovered)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Only successful responses are remembered. A failed request changed
+		// nothing, so the client may retry it with the same key.
+		if recorder.Status() >= http.StatusMultipleChoices {
+			releaseIdempotencyKey(idempotencyCollection, key, lease)
+			return
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err = database.CompleteIdempotentRequest(ctx, idempotencyCollection, key, lease, recorder.Status(), recorder.body.Bytes()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func releaseIdempotencyKey(idempotencyCollection *mongo.Collection, key string, lease primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := database.ReleaseIdempotentRequest(ctx, idempotencyCollection, key, lease); err != nil {
+		log.Println(err)
+	}
+}
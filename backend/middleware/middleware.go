@@ -33,6 +33,23 @@ func Authorization() gin.HandlerFunc {
 
 		c.Set("phone", claims.Phone)
 		c.Set("uid", claims.Uid)
+		c.Set("role", claims.Role)
+		c.Next()
+	}
+}
+
+// AdminOnly lets only admins through. It must run after Authorization.
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var response models.Response
+		if c.GetString("role") != models.RoleAdmin {
+			response.Status = "Failed"
+			response.Code = http.StatusForbidden
+			response.Msg = "Admin access required"
+			c.IndentedJSON(http.StatusForbidden, response)
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
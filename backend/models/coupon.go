@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CouponType string
+
+const (
+	// CouponPercentage takes Value basis points (1/100 of a percent) off the
+	// eligible lines.
+	CouponPercentage CouponType = "percentage"
+	// CouponFixed takes Value off the eligible lines.
+	CouponFixed CouponType = "fixed"
+)
+
+// Coupon is a discount code customers apply to their cart. A coupon without
+// products or categories applies to the whole cart; otherwise only the lines
+// of those products or categories are discounted.
+type Coupon struct {
+	CouponId     primitive.ObjectID   `json:"coupon_id"      bson:"_id"`
+	Code         string               `json:"code"           bson:"code"`
+	Type         CouponType           `json:"type"           bson:"type"`
+	Value        uint64               `json:"value"          bson:"value"`
+	MaxDiscount  uint64               `json:"max_discount"   bson:"max_discount"`
+	MinSpend     uint64               `json:"min_spend"      bson:"min_spend"`
+	StartsAt     *time.Time           `json:"starts_at"      bson:"starts_at"`
+	EndsAt       *time.Time           `json:"ends_at"        bson:"ends_at"`
+	UsageLimit   uint64               `json:"usage_limit"    bson:"usage_limit"`
+	PerUserLimit uint64               `json:"per_user_limit" bson:"per_user_limit"`
+	UsedCount    uint64               `json:"used_count"     bson:"used_count"`
+	ProductIds   []primitive.ObjectID `json:"product_ids"    bson:"product_ids"`
+	Categories   []string             `json:"categories"     bson:"categories"`
+	Active       bool                 `json:"active"         bson:"active"`
+	CreatedAt    time.Time            `json:"created_at"     bson:"created_at"`
+}
+
+// CouponRequest is what an admin sends to create or change a coupon. Limits of
+// zero mean unlimited.
+type CouponRequest struct {
+	Code         string               `json:"code"           validate:"required,alphanum,max=32"`
+	Type         CouponType           `json:"type"           validate:"required,oneof=percentage fixed"`
+	Value        uint64               `json:"value"          validate:"required"`
+	MaxDiscount  uint64               `json:"max_discount"`
+	MinSpend     uint64               `json:"min_spend"`
+	StartsAt     *time.Time           `json:"starts_at"`
+	EndsAt       *time.Time           `json:"ends_at"`
+	UsageLimit   uint64               `json:"usage_limit"`
+	PerUserLimit uint64               `json:"per_user_limit"`
+	ProductIds   []primitive.ObjectID `json:"product_ids"`
+	Categories   []string             `json:"categories"`
+	Active       bool                 `json:"active"`
+}
+
+// CouponRedemption counts how often one user has used a coupon on orders that
+// were not cancelled.
+type CouponRedemption struct {
+	Key        string `bson:"_id"`
+	CouponCode string `bson:"coupon_code"`
+	UserId     string `bson:"user_id"`
+	Count      uint64 `bson:"count"`
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code" validate:"required"`
+}
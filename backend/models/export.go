@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportRunning ExportStatus = "running"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// ExportRequest asks for the orders or order lines placed from From up to, but
+// not including, To. Without Columns every column is exported.
+type ExportRequest struct {
+	Dataset string    `json:"dataset" bson:"dataset"`
+	Format  string    `json:"format"  bson:"format"`
+	From    time.Time `json:"from"    bson:"from"`
+	To      time.Time `json:"to"      bson:"to"`
+	Columns []string  `json:"columns" bson:"columns"`
+}
+
+// ExportJob is an export too large to stream, written in the background. Its
+// file can be downloaded once it is ready.
+type ExportJob struct {
+	JobId         primitive.ObjectID `json:"job_id" bson:"_id"`
+	ExportRequest `bson:",inline"`
+	Status        ExportStatus       `json:"status"      bson:"status"`
+	Rows          int64              `json:"rows"        bson:"rows"`
+	Error         string             `json:"error,omitempty"       bson:"error,omitempty"`
+	FileId        primitive.ObjectID `json:"-"           bson:"file_id,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"  bson:"created_at"`
+	FinishedAt    *time.Time         `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
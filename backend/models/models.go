@@ -19,7 +19,28 @@ type User struct {
 	UserId         string             `json:"user_id"`
 	UserCart       []Product          `json:"user_cart" bson:"user_cart"`
 	AddressDetails []Address          `json:"addresses" bson:"addresses"`
-	Orders         []Order            `json:"orders" bson:"orders"`
+	StoreCredit    uint64             `json:"store_credit" bson:"store_credit"`
+	AppliedCoupon  string             `json:"applied_coupon" bson:"applied_coupon,omitempty"`
+	// DefaultShippingAddress and DefaultBillingAddress are ids of addresses in
+	// AddressDetails.
+	DefaultShippingAddress primitive.ObjectID `json:"default_shipping_address" bson:"default_shipping_address,omitempty"`
+	DefaultBillingAddress  primitive.ObjectID `json:"default_billing_address" bson:"default_billing_address,omitempty"`
+	// Role is granted in the database and never taken from requests.
+	Role string `json:"-" bson:"role,omitempty"`
+}
+
+// AddressRequest adds or edits an address in the address book, optionally
+// making it the default for shipping or billing.
+type AddressRequest struct {
+	Address
+	DefaultShipping bool `json:"default_shipping"`
+	DefaultBilling  bool `json:"default_billing"`
+}
+
+type AddressBook struct {
+	Addresses              []Address          `json:"addresses"`
+	DefaultShippingAddress primitive.ObjectID `json:"default_shipping_address"`
+	DefaultBillingAddress  primitive.ObjectID `json:"default_billing_address"`
 }
 
 type Product struct {
@@ -28,30 +49,162 @@ type Product struct {
 	Price       uint64             `json:"price"`
 	Rating      float32            `json:"rating"`
 	Image       string             `json:"image"`
-	Comments    []Comment          `json:"comments" bson:"comments"`
+	Category    string             `json:"category,omitempty" bson:"category,omitempty"`
+	TaxClass    string             `json:"tax_class,omitempty" bson:"tax_class,omitempty"`
+	// Weight is in grams.
+	Weight   uint64    `json:"weight,omitempty" bson:"weight,omitempty"`
+	Quantity uint64    `json:"quantity,omitempty" bson:"quantity,omitempty"`
+	Stock    *int64    `json:"stock,omitempty"    bson:"stock,omitempty"`
+	Comments []Comment `json:"comments" bson:"comments"`
+}
+
+// MaxCartQuantity is the most units of one product a cart line or an order can
+// hold.
+const MaxCartQuantity = 1000
+
+// RoleAdmin is the role of users allowed on the admin routes.
+const RoleAdmin = "admin"
+
+// CartQuantity is the number of units a cart line stands for. Lines added
+// before quantities were tracked count as a single unit.
+func (p Product) CartQuantity() uint64 {
+	if p.Quantity == 0 {
+		return 1
+	}
+	return p.Quantity
+}
+
+type GuestCart struct {
+	CartId    primitive.ObjectID `json:"cart_id"    bson:"_id"`
+	Items     []Product          `json:"items"      bson:"items"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
 type Address struct {
-	AddressId primitive.ObjectID `bson:"_id"`
-	House     string             `json:"house" bson:"house"`
-	Street    string             `json:"street" bson:"street"`
-	Ward      string             `json:"ward" bson:"ward"`
-	District  string             `json:"district" bson:"district"`
-	City      string             `json:"city" bson:"city"`
+	AddressId primitive.ObjectID `json:"address_id" bson:"_id"`
+	// Label names the address in the address book, such as home or work.
+	Label         string `json:"label"          bson:"label,omitempty"          validate:"max=40"`
+	RecipientName string `json:"recipient_name" bson:"recipient_name,omitempty" validate:"max=60"`
+	Phone         string `json:"phone"          bson:"phone,omitempty"          validate:"max=20"`
+	House         string `json:"house"          bson:"house"                    validate:"required,max=100"`
+	Street        string `json:"street"         bson:"street"                   validate:"required,max=100"`
+	Ward          string `json:"ward"           bson:"ward"                     validate:"max=100"`
+	District      string `json:"district"       bson:"district"                 validate:"required,max=100"`
+	City          string `json:"city"           bson:"city"                     validate:"required,max=100"`
+	// CityCode, DistrictCode and WardCode are the official codes of the
+	// divisions, filled in when the address is checked.
+	CityCode     string `json:"city_code"     bson:"city_code,omitempty"     validate:"max=10"`
+	DistrictCode string `json:"district_code" bson:"district_code,omitempty" validate:"max=10"`
+	WardCode     string `json:"ward_code"     bson:"ward_code,omitempty"     validate:"max=10"`
 }
 
 type Order struct {
-	OrderId       primitive.ObjectID `bson:"_id"`
-	OrderCart     []Product          `json:"order_list"  bson:"order_list"`
-	OrderedAt     time.Time          `json:"ordered_at"  bson:"ordered_at"`
-	Price         uint64             `json:"total_price" bson:"total_price"`
-	Discount      int                `json:"discount"    bson:"discount"`
-	PaymentMethod Payment            `json:"payment_method" bson:"payment_method"`
+	OrderId primitive.ObjectID `bson:"_id"`
+	// OrderNumber is the number customers know the order by, such as
+	// EC-2026-000123. Orders placed before numbers were given have none.
+	OrderNumber   string         `json:"order_number,omitempty" bson:"order_number,omitempty"`
+	UserId        string         `json:"user_id"     bson:"user_id"`
+	OrderCart     []Product      `json:"order_list"  bson:"order_list"`
+	OrderedAt     time.Time      `json:"ordered_at"  bson:"ordered_at"`
+	Price         uint64         `json:"total_price" bson:"total_price"`
+	Discount      int            `json:"discount"    bson:"discount"`
+	PaymentMethod Payment        `json:"payment_method" bson:"payment_method"`
+	Pricing       PriceBreakdown `json:"pricing"     bson:"pricing"`
+	// ShippingAddress is a copy of the address taken when the order was placed.
+	ShippingAddress Address `json:"shipping_address" bson:"shipping_address"`
+	// BillingAddress is a copy of the user's default billing address, if any.
+	BillingAddress *Address       `json:"billing_address,omitempty" bson:"billing_address,omitempty"`
+	Status         OrderStatus    `json:"status"           bson:"status"`
+	StatusHistory  []StatusChange `json:"status_history"   bson:"status_history"`
+	Cancellation   *Cancellation  `json:"cancellation,omitempty" bson:"cancellation,omitempty"`
+	Refunds        []Refund       `json:"refunds,omitempty"      bson:"refunds,omitempty"`
+	// ReturnedQuantities counts per product id the units that requested,
+	// received and approved returns cover. Orders whose returns were not
+	// counted yet have none.
+	ReturnedQuantities map[string]uint64 `json:"returned_quantities,omitempty" bson:"returned_qty,omitempty"`
+	// ShipmentsCreated counts the shipments created for the order. Creating
+	// one always writes the order, so that a cancellation racing with it
+	// conflicts instead of missing the new shipment.
+	ShipmentsCreated uint64 `json:"-" bson:"shipments_created,omitempty"`
+	// StoreCreditUsed is the part of Price paid with the user's store credit.
+	// The payment intent covers the rest.
+	StoreCreditUsed uint64 `json:"store_credit_used,omitempty" bson:"store_credit_used,omitempty"`
+}
+
+type OrderLine struct {
+	ProductId   primitive.ObjectID `json:"product_id"   bson:"product_id"`
+	ProductName string             `json:"product_name" bson:"product_name"`
+	UnitPrice   uint64             `json:"unit_price"   bson:"unit_price"`
+	Quantity    uint64             `json:"quantity"     bson:"quantity"`
+	LineTotal   uint64             `json:"line_total"   bson:"line_total"`
+	// Discount is the line's share of the order discount.
+	Discount     uint64 `json:"discount"      bson:"discount"`
+	TaxClass     string `json:"tax_class"     bson:"tax_class"`
+	TaxRate      uint64 `json:"tax_rate"      bson:"tax_rate"`
+	TaxInclusive bool   `json:"tax_inclusive" bson:"tax_inclusive"`
+	// Tax is charged on the line total less the discount.
+	Tax uint64 `json:"tax" bson:"tax"`
+}
+
+type PriceBreakdown struct {
+	Lines    []OrderLine `json:"lines"    bson:"lines"`
+	Subtotal uint64      `json:"subtotal" bson:"subtotal"`
+	Discount uint64      `json:"discount" bson:"discount"`
+	// Promotions are the automatic promotions included in the discount.
+	Promotions []AppliedPromotion `json:"promotions,omitempty" bson:"promotions,omitempty"`
+	// CouponCode is the coupon that took CouponDiscount of the discount, if any.
+	CouponCode     string `json:"coupon_code,omitempty"     bson:"coupon_code,omitempty"`
+	CouponDiscount uint64 `json:"coupon_discount,omitempty" bson:"coupon_discount,omitempty"`
+	Shipping       uint64 `json:"shipping" bson:"shipping"`
+	// Delivery is the delivery option Shipping was charged for.
+	Delivery *ShippingQuote `json:"delivery,omitempty" bson:"delivery,omitempty"`
+	Tax      uint64         `json:"tax"      bson:"tax"`
+	// TaxIncluded is the part of Tax that the prices already include.
+	TaxIncluded uint64 `json:"tax_included" bson:"tax_included"`
+	Total       uint64 `json:"total"    bson:"total"`
 }
 
 type Payment struct {
-	Digital bool `json:"digital" bson:"digital"`
-	COD     bool `json:"cod"     bson:"cod"`
+	Digital  bool               `json:"digital"   bson:"digital"`
+	COD      bool               `json:"cod"       bson:"cod"`
+	Method   string             `json:"method"    bson:"method"`
+	IntentId primitive.ObjectID `json:"intent_id" bson:"intent_id"`
+}
+
+// Payment methods, named after the provider that handles them.
+const (
+	PaymentCOD      = "cod"
+	PaymentFakeCard = "fake_card"
+)
+
+// PaymentStoreCredit is the payment method of orders paid entirely with store
+// credit, which have no payment intent.
+const PaymentStoreCredit = "store_credit"
+
+type CheckoutOptions struct {
+	// AddressId picks one of the user's saved addresses. Address ships to a
+	// new address instead, without saving it.
+	AddressId     string   `json:"address_id"`
+	Address       *Address `json:"address"`
+	PaymentMethod string   `json:"payment_method"`
+	// PaymentSource is the method specific reference to pay with, such as a
+	// card token.
+	PaymentSource string `json:"payment_source"`
+	// CouponCode replaces the coupon applied to the cart, if any.
+	CouponCode string `json:"coupon_code"`
+	// DeliveryOption is standard unless another is chosen.
+	DeliveryOption string `json:"delivery_option"`
+	// UseStoreCredit pays as much of the order as the user's store credit
+	// covers. The payment method pays the rest.
+	UseStoreCredit bool `json:"use_store_credit"`
+}
+
+type InstantBuyRequest struct {
+	ProductId string `json:"product_id" validate:"required"`
+	// Quantity is at most MaxCartQuantity.
+	Quantity uint64 `json:"quantity" validate:"max=1000"`
+	CheckoutOptions
 }
 
 type Comment struct {
@@ -61,6 +214,40 @@ type Comment struct {
 	Content   string             `json:"content" bson:"content"`
 }
 
+type CartChange struct {
+	ProductId   primitive.ObjectID `json:"product_id"   bson:"product_id"`
+	ProductName string             `json:"product_name" bson:"product_name"`
+	Reason      string             `json:"reason"       bson:"reason"`
+	OldPrice    uint64             `json:"old_price"    bson:"old_price"`
+	NewPrice    uint64             `json:"new_price"    bson:"new_price"`
+}
+
+// CartReview is what a customer is asked to confirm when their cart changed:
+// the changes and the fingerprint of the cart as it now stands, which checkout
+// takes back as the confirmation.
+type CartReview struct {
+	Changes     []CartChange `json:"changes"`
+	Fingerprint string       `json:"fingerprint"`
+}
+
+const (
+	CartPriceChanged    = "price_changed"
+	CartItemUnavailable = "unavailable"
+)
+
+type IdempotencyRecord struct {
+	Key         string `json:"key"           bson:"_id"`
+	Fingerprint string `json:"fingerprint"   bson:"fingerprint"`
+	Completed   bool   `json:"completed"     bson:"completed"`
+	// LeaseId and LockedUntil say who runs the request and until when. A
+	// retry takes over requests whose lease ran out without completing.
+	LeaseId      primitive.ObjectID `json:"lease_id"      bson:"lease_id"`
+	LockedUntil  time.Time          `json:"locked_until"  bson:"locked_until"`
+	ResponseCode int                `json:"response_code" bson:"response_code"`
+	ResponseBody []byte             `json:"response_body" bson:"response_body"`
+	CreatedAt    time.Time          `json:"created_at"    bson:"created_at"`
+}
+
 type Response struct {
 	Status string      `json:"status"`
 	Code   uint        `json:"code"`
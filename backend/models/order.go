@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	OrderPendingPayment OrderStatus = "pending_payment"
+	OrderPaid           OrderStatus = "paid"
+	OrderProcessing     OrderStatus = "processing"
+	OrderShipped        OrderStatus = "shipped"
+	OrderDelivered      OrderStatus = "delivered"
+	OrderCancelled      OrderStatus = "cancelled"
+	OrderRefunded       OrderStatus = "refunded"
+)
+
+// orderTransitions lists, for every status, the statuses an order may move to.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPendingPayment: {OrderPaid, OrderCancelled},
+	OrderPaid:           {OrderProcessing, OrderCancelled, OrderRefunded},
+	OrderProcessing:     {OrderShipped, OrderCancelled},
+	OrderShipped:        {OrderDelivered},
+	OrderDelivered:      {OrderRefunded},
+	OrderCancelled:      {OrderRefunded},
+	OrderRefunded:       {},
+}
+
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type StatusChange struct {
+	From      OrderStatus `json:"from"       bson:"from"`
+	To        OrderStatus `json:"to"         bson:"to"`
+	ChangedAt time.Time   `json:"changed_at" bson:"changed_at"`
+	Note      string      `json:"note"       bson:"note"`
+}
+
+// InitialOrderStatus is the status an order is placed in. Cash on delivery
+// orders are paid when they arrive and go straight to processing.
+func InitialOrderStatus(payment Payment) OrderStatus {
+	if payment.COD {
+		return OrderProcessing
+	}
+	return OrderPendingPayment
+}
+
+// CurrentStatus is the order's status. Orders placed before statuses were
+// recorded were all cash on delivery and count as processing.
+func (o Order) CurrentStatus() OrderStatus {
+	if o.Status == "" {
+		return OrderProcessing
+	}
+	return o.Status
+}
+
+type OrderStatusRequest struct {
+	Status OrderStatus `json:"status" validate:"required"`
+	Note   string      `json:"note"`
+}
+
+// CustomerCancellable reports whether the customer may still cancel an order in
+// this status, which is the case until it has been shipped.
+func (s OrderStatus) CustomerCancellable() bool {
+	return s == OrderPendingPayment || s == OrderPaid || s == OrderProcessing
+}
+
+const (
+	PaymentActionNone    = "none"
+	PaymentActionVoid    = "void"
+	PaymentActionRefund  = "refund"
+	PaymentActionCapture = "capture"
+)
+
+type Cancellation struct {
+	Reason        string    `json:"reason"         bson:"reason"`
+	CancelledAt   time.Time `json:"cancelled_at"   bson:"cancelled_at"`
+	PaymentAction string    `json:"payment_action" bson:"payment_action"`
+}
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// BulkOrderStatusRequest moves many orders to the same status at once.
+type BulkOrderStatusRequest struct {
+	OrderIds []string    `json:"order_ids" validate:"required,min=1,max=500,dive,required"`
+	Status   OrderStatus `json:"status"    validate:"required"`
+	Note     string      `json:"note"`
+}
+
+// BulkOrderStatusResult is what became of one order of a bulk status change.
+// Error says why the order was left as it was.
+type BulkOrderStatusResult struct {
+	OrderId string      `json:"order_id"`
+	Status  OrderStatus `json:"status,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// OrderCustomer is who placed an order, as shown to staff.
+type OrderCustomer struct {
+	UserId    string `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Phone     string `json:"phone"`
+	// Orders is how many orders the customer has placed in all.
+	Orders int64 `json:"orders"`
+}
+
+// AdminOrder is an order with the customer who placed it. Customer is nil
+// when the customer's account no longer exists.
+type AdminOrder struct {
+	Order    Order          `json:"order"`
+	Customer *OrderCustomer `json:"customer"`
+}
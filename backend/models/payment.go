@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentPending           PaymentStatus = "pending"
+	PaymentAuthorized        PaymentStatus = "authorized"
+	PaymentCaptured          PaymentStatus = "captured"
+	PaymentVoided            PaymentStatus = "voided"
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentRefunded          PaymentStatus = "refunded"
+	PaymentFailed            PaymentStatus = "failed"
+)
+
+// PaymentIntent tracks the payment of one order with a payment provider.
+type PaymentIntent struct {
+	IntentId       primitive.ObjectID `json:"intent_id"       bson:"_id"`
+	OrderId        primitive.ObjectID `json:"order_id"        bson:"order_id"`
+	UserId         string             `json:"user_id"         bson:"user_id"`
+	Provider       string             `json:"provider"        bson:"provider"`
+	Amount         uint64             `json:"amount"          bson:"amount"`
+	Currency       string             `json:"currency"        bson:"currency"`
+	Status         PaymentStatus      `json:"status"          bson:"status"`
+	ProviderRef    string             `json:"provider_ref"    bson:"provider_ref"`
+	RefundedAmount uint64             `json:"refunded_amount" bson:"refunded_amount"`
+	FailureReason  string             `json:"failure_reason"  bson:"failure_reason"`
+	// Pending are the provider calls decided for the payment that have not
+	// been made yet.
+	Pending   []PaymentOperation `json:"pending,omitempty" bson:"pending,omitempty"`
+	CreatedAt time.Time          `json:"created_at"      bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"      bson:"updated_at"`
+}
+
+// PaymentOperation is a provider call decided inside an order transaction. It
+// is queued on the payment intent and made once the transaction commits, so
+// that a transaction that is retried or aborted never moves money.
+type PaymentOperation struct {
+	OperationId primitive.ObjectID `json:"operation_id"        bson:"_id"`
+	// Action is one of PaymentActionCapture, PaymentActionVoid and
+	// PaymentActionRefund.
+	Action string `json:"action"              bson:"action"`
+	Amount uint64 `json:"amount,omitempty"    bson:"amount,omitempty"`
+	// Reference identifies a refund to the provider, so that making the call
+	// again does not pay twice.
+	Reference string    `json:"reference,omitempty" bson:"reference,omitempty"`
+	QueuedAt  time.Time `json:"queued_at"           bson:"queued_at"`
+}
+
+// PaymentEvent is a change to a payment reported by its provider. For refunds
+// Amount is the total refunded so far.
+type PaymentEvent struct {
+	EventId       string        `json:"event_id"                 bson:"event_id"`
+	Provider      string        `json:"provider"                 bson:"provider"`
+	Type          string        `json:"type"                     bson:"type"`
+	ProviderRef   string        `json:"provider_ref"             bson:"provider_ref"`
+	Status        PaymentStatus `json:"status"                   bson:"status"`
+	Amount        uint64        `json:"amount"                   bson:"amount"`
+	FailureReason string        `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	OccurredAt    time.Time     `json:"occurred_at"              bson:"occurred_at"`
+}
+
+// WebhookEvent records a provider event that was processed, keyed by the
+// provider and its event id so that redelivered events are recognised.
+type WebhookEvent struct {
+	Key        string             `bson:"_id"`
+	Event      PaymentEvent       `bson:"event"`
+	IntentId   primitive.ObjectID `bson:"intent_id"`
+	ReceivedAt time.Time          `bson:"received_at"`
+}
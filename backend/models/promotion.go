@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PromotionType string
+
+const (
+	// PromotionBuyXGetY gives FreeQuantity units free for every BuyQuantity
+	// units bought of the eligible products. The cheapest units are the free
+	// ones.
+	PromotionBuyXGetY PromotionType = "buy_x_get_y"
+	// PromotionTiered takes the best tier the cart subtotal reaches off it.
+	PromotionTiered PromotionType = "tiered"
+	// PromotionBundle sells every complete set of BundleItems for BundlePrice.
+	PromotionBundle PromotionType = "bundle"
+)
+
+// PromotionTier takes Rate basis points, or Amount when Rate is zero, off
+// carts whose subtotal is at least MinSubtotal.
+type PromotionTier struct {
+	MinSubtotal uint64 `json:"min_subtotal" bson:"min_subtotal"`
+	Rate        uint64 `json:"rate"         bson:"rate"`
+	Amount      uint64 `json:"amount"       bson:"amount"`
+}
+
+type BundleItem struct {
+	ProductId primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Quantity  uint64             `json:"quantity"   bson:"quantity"`
+}
+
+// Promotion is a discount applied to carts automatically. Promotions are
+// evaluated from the highest priority down. Units of a product that one
+// promotion discounted are not discounted again by a later one. A promotion
+// that is not stackable only applies when no other promotion did and stops
+// any further ones from applying.
+type Promotion struct {
+	PromotionId         primitive.ObjectID   `json:"promotion_id"          bson:"_id"`
+	Name                string               `json:"name"                  bson:"name"`
+	Type                PromotionType        `json:"type"                  bson:"type"`
+	Priority            int                  `json:"priority"              bson:"priority"`
+	Stackable           bool                 `json:"stackable"             bson:"stackable"`
+	CombinesWithCoupons bool                 `json:"combines_with_coupons" bson:"combines_with_coupons"`
+	Active              bool                 `json:"active"                bson:"active"`
+	StartsAt            *time.Time           `json:"starts_at"             bson:"starts_at"`
+	EndsAt              *time.Time           `json:"ends_at"               bson:"ends_at"`
+	ProductIds          []primitive.ObjectID `json:"product_ids"           bson:"product_ids"`
+	Categories          []string             `json:"categories"            bson:"categories"`
+	BuyQuantity         uint64               `json:"buy_quantity"          bson:"buy_quantity"`
+	FreeQuantity        uint64               `json:"free_quantity"         bson:"free_quantity"`
+	Tiers               []PromotionTier      `json:"tiers"                 bson:"tiers"`
+	BundleItems         []BundleItem         `json:"bundle_items"          bson:"bundle_items"`
+	BundlePrice         uint64               `json:"bundle_price"          bson:"bundle_price"`
+	CreatedAt           time.Time            `json:"created_at"            bson:"created_at"`
+}
+
+// PromotionRequest is what an admin sends to create or change a promotion.
+type PromotionRequest struct {
+	Name                string               `json:"name"     validate:"required,max=100"`
+	Type                PromotionType        `json:"type"     validate:"required,oneof=buy_x_get_y tiered bundle"`
+	Priority            int                  `json:"priority"`
+	Stackable           bool                 `json:"stackable"`
+	CombinesWithCoupons bool                 `json:"combines_with_coupons"`
+	Active              bool                 `json:"active"`
+	StartsAt            *time.Time           `json:"starts_at"`
+	EndsAt              *time.Time           `json:"ends_at"`
+	ProductIds          []primitive.ObjectID `json:"product_ids"`
+	Categories          []string             `json:"categories"`
+	BuyQuantity         uint64               `json:"buy_quantity"`
+	FreeQuantity        uint64               `json:"free_quantity"`
+	Tiers               []PromotionTier      `json:"tiers"`
+	BundleItems         []BundleItem         `json:"bundle_items"`
+	BundlePrice         uint64               `json:"bundle_price"`
+}
+
+// AppliedPromotion explains what a promotion took off a cart.
+type AppliedPromotion struct {
+	PromotionId primitive.ObjectID `json:"promotion_id" bson:"promotion_id"`
+	Name        string             `json:"name"         bson:"name"`
+	Type        PromotionType      `json:"type"         bson:"type"`
+	Discount    uint64             `json:"discount"     bson:"discount"`
+	Description string             `json:"description"  bson:"description"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnReceived  ReturnStatus = "received"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+)
+
+const (
+	RefundToOriginalPayment = "original_payment"
+	RefundToStoreCredit     = "store_credit"
+)
+
+// ReturnWindow is how long after delivery a customer can ask for a return.
+const ReturnWindow = 30 * 24 * time.Hour
+
+type ReturnLine struct {
+	ProductId   primitive.ObjectID `json:"product_id"   bson:"product_id"   validate:"required"`
+	ProductName string             `json:"product_name" bson:"product_name"`
+	Quantity    uint64             `json:"quantity"     bson:"quantity"     validate:"required,min=1"`
+}
+
+// Return is a customer's request to send back some of the lines of a
+// delivered order. It is approved once the goods have been received.
+type Return struct {
+	ReturnId     primitive.ObjectID `json:"return_id"     bson:"_id"`
+	OrderId      primitive.ObjectID `json:"order_id"      bson:"order_id"`
+	UserId       string             `json:"user_id"       bson:"user_id"`
+	Lines        []ReturnLine       `json:"lines"         bson:"lines"`
+	Reason       string             `json:"reason"        bson:"reason"`
+	Photos       []string           `json:"photos"        bson:"photos"`
+	RefundMethod string             `json:"refund_method" bson:"refund_method"`
+	RefundAmount uint64             `json:"refund_amount" bson:"refund_amount"`
+	Status       ReturnStatus       `json:"status"        bson:"status"`
+	AdminNote    string             `json:"admin_note"    bson:"admin_note"`
+	RequestedAt  time.Time          `json:"requested_at"  bson:"requested_at"`
+	ReceivedAt   *time.Time         `json:"received_at,omitempty" bson:"received_at,omitempty"`
+	DecidedAt    *time.Time         `json:"decided_at,omitempty"  bson:"decided_at,omitempty"`
+}
+
+type ReturnRequest struct {
+	Lines        []ReturnLine `json:"lines"         validate:"required,min=1,dive"`
+	Reason       string       `json:"reason"        validate:"required,max=1000"`
+	Photos       []string     `json:"photos"        validate:"max=10,dive,url"`
+	RefundMethod string       `json:"refund_method" validate:"omitempty,oneof=original_payment store_credit"`
+}
+
+type ReturnDecision struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+// Refund is money paid back for an order, for a cancellation or a return.
+type Refund struct {
+	Amount     uint64    `json:"amount"      bson:"amount"`
+	Method     string    `json:"method"      bson:"method"`
+	Reference  string    `json:"reference"   bson:"reference"`
+	RefundedAt time.Time `json:"refunded_at" bson:"refunded_at"`
+}
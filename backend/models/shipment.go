@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ShipmentStatus string
+
+const (
+	ShipmentPacking        ShipmentStatus = "packing"
+	ShipmentInTransit      ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentFailedAttempt  ShipmentStatus = "failed_attempt"
+	ShipmentDelivered      ShipmentStatus = "delivered"
+	// ShipmentReturned is a package the carrier brought back to the store. Its
+	// items can be shipped again in another package.
+	ShipmentReturned ShipmentStatus = "returned"
+	// ShipmentCancelled is a package called off before the carrier picked it
+	// up.
+	ShipmentCancelled ShipmentStatus = "cancelled"
+)
+
+// Final reports whether a shipment in this status takes no further events.
+func (s ShipmentStatus) Final() bool {
+	return s == ShipmentDelivered || s == ShipmentReturned || s == ShipmentCancelled
+}
+
+// Active reports whether a shipment in this status still carries its items,
+// which other shipments then cannot carry again.
+func (s ShipmentStatus) Active() bool {
+	return s != ShipmentReturned && s != ShipmentCancelled
+}
+
+// Dispatched reports whether a shipment in this status has left the store.
+func (s ShipmentStatus) Dispatched() bool {
+	return s.Active() && s != ShipmentPacking
+}
+
+type ShipmentLine struct {
+	ProductId   primitive.ObjectID `json:"product_id"   bson:"product_id"   validate:"required"`
+	ProductName string             `json:"product_name" bson:"product_name"`
+	Quantity    uint64             `json:"quantity"     bson:"quantity"     validate:"required,min=1"`
+}
+
+type TrackingEvent struct {
+	Status      ShipmentStatus `json:"status"      bson:"status"`
+	Description string         `json:"description" bson:"description"`
+	Location    string         `json:"location"    bson:"location"`
+	OccurredAt  time.Time      `json:"occurred_at" bson:"occurred_at"`
+}
+
+// Shipment is a package sent for some or all of the lines of an order. An order
+// can be split over several shipments.
+type Shipment struct {
+	ShipmentId     primitive.ObjectID `json:"shipment_id"     bson:"_id"`
+	OrderId        primitive.ObjectID `json:"order_id"        bson:"order_id"`
+	UserId         string             `json:"user_id"         bson:"user_id"`
+	Lines          []ShipmentLine     `json:"lines"           bson:"lines"`
+	Carrier        string             `json:"carrier"         bson:"carrier"`
+	TrackingNumber string             `json:"tracking_number" bson:"tracking_number"`
+	// LabelRef identifies the shipping label the carrier issued.
+	LabelRef    string          `json:"label_ref,omitempty" bson:"label_ref,omitempty"`
+	Status      ShipmentStatus  `json:"status"          bson:"status"`
+	Events      []TrackingEvent `json:"events"          bson:"events"`
+	CreatedAt   time.Time       `json:"created_at"      bson:"created_at"`
+	ShippedAt   *time.Time      `json:"shipped_at,omitempty"   bson:"shipped_at,omitempty"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+}
+
+// ShipmentRequest packs lines of an order into a new shipment. Without lines
+// everything not yet shipped is packed. Without a carrier the shipment goes
+// with the carrier of the delivery option the customer chose, or else with
+// the manual carrier, for which TrackingNumber is the delivery company's.
+type ShipmentRequest struct {
+	Lines          []ShipmentLine `json:"lines"           validate:"dive"`
+	Carrier        string         `json:"carrier"         validate:"max=50"`
+	TrackingNumber string         `json:"tracking_number" validate:"max=100"`
+}
+
+type CancelShipmentRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type TrackingEventRequest struct {
+	Status      ShipmentStatus `json:"status"      validate:"required,oneof=in_transit out_for_delivery failed_attempt delivered returned"`
+	Description string         `json:"description" validate:"max=500"`
+	Location    string         `json:"location"    validate:"max=200"`
+	// OccurredAt defaults to when the event is recorded.
+	OccurredAt *time.Time `json:"occurred_at"`
+}
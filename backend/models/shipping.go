@@ -0,0 +1,72 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	DeliveryStandard = "standard"
+	DeliveryExpress  = "express"
+)
+
+type ShippingBasis string
+
+const (
+	// ShippingByWeight prices a delivery on the total weight in grams.
+	ShippingByWeight ShippingBasis = "weight"
+	// ShippingByOrderValue prices a delivery on the value of the goods after
+	// discounts.
+	ShippingByOrderValue ShippingBasis = "order_value"
+)
+
+// ShippingRegion is a city, or one district of it when District is set.
+type ShippingRegion struct {
+	City     string `json:"city"     bson:"city"     validate:"required"`
+	District string `json:"district" bson:"district"`
+}
+
+// RateBracket charges Price for weights or order values up to UpTo. The
+// bracket with an UpTo of zero has no upper bound.
+type RateBracket struct {
+	UpTo  uint64 `json:"up_to" bson:"up_to"`
+	Price uint64 `json:"price" bson:"price"`
+}
+
+// ShippingRate is how one delivery option is charged within a zone, by its
+// brackets or, when Carrier is set, by what that carrier quotes. Orders whose
+// goods are worth at least FreeOver ship for free; zero means never.
+type ShippingRate struct {
+	Option   string        `json:"option"    bson:"option"    validate:"required,oneof=standard express"`
+	Carrier  string        `json:"carrier"   bson:"carrier,omitempty"`
+	Basis    ShippingBasis `json:"basis"     bson:"basis"     validate:"required_without=Carrier,omitempty,oneof=weight order_value"`
+	Brackets []RateBracket `json:"brackets"  bson:"brackets"  validate:"required_without=Carrier,omitempty,min=1"`
+	FreeOver uint64        `json:"free_over" bson:"free_over"`
+	MinDays  int           `json:"min_days"  bson:"min_days"`
+	MaxDays  int           `json:"max_days"  bson:"max_days"`
+}
+
+// ShippingZone groups the regions that share shipping rates. An address is
+// served by the zone that names its district, else by the one that names its
+// city, else by a zone without regions, which covers everywhere else.
+type ShippingZone struct {
+	ZoneId  primitive.ObjectID `json:"zone_id" bson:"_id"`
+	Name    string             `json:"name"    bson:"name"`
+	Regions []ShippingRegion   `json:"regions" bson:"regions"`
+	Rates   []ShippingRate     `json:"rates"   bson:"rates"`
+}
+
+type ShippingZoneRequest struct {
+	Name    string           `json:"name"    validate:"required,max=100"`
+	Regions []ShippingRegion `json:"regions" validate:"dive"`
+	Rates   []ShippingRate   `json:"rates"   validate:"required,min=1,dive"`
+}
+
+// ShippingQuote is what one delivery option costs for a cart.
+type ShippingQuote struct {
+	Option   string             `json:"option"    bson:"option"`
+	Carrier  string             `json:"carrier,omitempty" bson:"carrier,omitempty"`
+	ZoneId   primitive.ObjectID `json:"zone_id"   bson:"zone_id"`
+	ZoneName string             `json:"zone_name" bson:"zone_name"`
+	Cost     uint64             `json:"cost"      bson:"cost"`
+	Free     bool               `json:"free"      bson:"free"`
+	MinDays  int                `json:"min_days"  bson:"min_days"`
+	MaxDays  int                `json:"max_days"  bson:"max_days"`
+}
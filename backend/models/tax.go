@@ -0,0 +1,32 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// TaxClassStandard is the tax class of products that do not name one.
+const TaxClassStandard = "standard"
+
+// TaxRate is the tax charged on products of a tax class shipped to a region.
+// An empty city or district matches any, and the most specific rate that
+// matches an address wins: district over city over the whole country.
+// Inclusive rates are already part of the product prices, so they are shown
+// but not added to the total.
+type TaxRate struct {
+	RateId    primitive.ObjectID `json:"rate_id"   bson:"_id"`
+	Name      string             `json:"name"      bson:"name"`
+	TaxClass  string             `json:"tax_class" bson:"tax_class"`
+	City      string             `json:"city"      bson:"city"`
+	District  string             `json:"district"  bson:"district"`
+	Rate      uint64             `json:"rate"      bson:"rate"`
+	Inclusive bool               `json:"inclusive" bson:"inclusive"`
+}
+
+// TaxRateRequest is what an admin sends to create or change a tax rate. Rate
+// is in basis points (1/100 of a percent).
+type TaxRateRequest struct {
+	Name      string `json:"name"      validate:"required,max=100"`
+	TaxClass  string `json:"tax_class" validate:"required,max=50"`
+	City      string `json:"city"      validate:"max=100"`
+	District  string `json:"district"  validate:"max=100"`
+	Rate      uint64 `json:"rate"      validate:"max=10000"`
+	Inclusive bool   `json:"inclusive"`
+}
@@ -0,0 +1,52 @@
+package payment
+
+import (
+	"context"
+
+	"backend/models"
+)
+
+// COD is cash on delivery. No money moves until the courier collects it, so
+// the provider only keeps the intent's bookkeeping; refunds are paid out by
+// the store.
+type COD struct{}
+
+func (COD) Name() string {
+	return models.PaymentCOD
+}
+
+func (COD) DeferredCapture() bool {
+	return true
+}
+
+func (COD) Authorize(ctx context.Context, intent *models.PaymentIntent, source string) error {
+	if intent.Status != models.PaymentPending {
+		return ErrInvalidPaymentState
+	}
+	intent.Status = models.PaymentAuthorized
+	return nil
+}
+
+func (COD) Capture(ctx context.Context, intent *models.PaymentIntent) error {
+	if intent.Status != models.PaymentAuthorized {
+		return ErrInvalidPaymentState
+	}
+	intent.Status = models.PaymentCaptured
+	return nil
+}
+
+func (COD) Refund(ctx context.Context, intent *models.PaymentIntent, amount uint64, reference string) error {
+	return refund(intent, amount)
+}
+
+func (COD) Void(ctx context.Context, intent *models.PaymentIntent) error {
+	if intent.Status != models.PaymentPending && intent.Status != models.PaymentAuthorized {
+		return ErrInvalidPaymentState
+	}
+	intent.Status = models.PaymentVoided
+	return nil
+}
+
+func (COD) VerifyWebhook(payload []byte, signature string) (models.PaymentEvent, error) {
+	return models.PaymentEvent{}, ErrWebhookNotSupported
+}
@@ -0,0 +1,148 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/models"
+)
+
+// Card tokens understood by the fake card provider.
+const (
+	FakeCardApproved          = "tok_visa"
+	FakeCardAsync             = "tok_visa_async"
+	FakeCardDeclined          = "tok_declined"
+	FakeCardInsufficientFunds = "tok_insufficient_funds"
+)
+
+// Charges made with the async token are only confirmed by a later webhook,
+// like a real card network would.
+const (
+	fakeChargePrefix      = "fake_ch_"
+	fakeAsyncChargePrefix = "fake_pi_"
+)
+
+// FakeCard is a card provider for local testing. It approves the tok_visa and
+// tok_visa_async tokens and declines every other one without talking to any
+// network.
+type FakeCard struct {
+	webhookSecret []byte
+}
+
+func NewFakeCard(webhookSecret string) *FakeCard {
+	return &FakeCard{webhookSecret: []byte(webhookSecret)}
+}
+
+func (*FakeCard) Name() string {
+	return models.PaymentFakeCard
+}
+
+func (*FakeCard) DeferredCapture() bool {
+	return false
+}
+
+func (*FakeCard) Authorize(ctx context.Context, intent *models.PaymentIntent, source string) error {
+	if intent.Status != models.PaymentPending {
+		return ErrInvalidPaymentState
+	}
+	intent.ProviderRef = fakeChargePrefix + intent.OrderId.Hex()
+	switch source {
+	case FakeCardApproved:
+		intent.Status = models.PaymentAuthorized
+		return nil
+	case FakeCardAsync:
+		intent.ProviderRef = fakeAsyncChargePrefix + intent.OrderId.Hex()
+		intent.Status = models.PaymentAuthorized
+		return nil
+	case FakeCardInsufficientFunds:
+		intent.FailureReason = "insufficient_funds"
+	case "":
+		intent.FailureReason = "missing_card"
+	default:
+		intent.FailureReason = "card_declined"
+	}
+	intent.Status = models.PaymentFailed
+	return ErrDeclined
+}
+
+func (*FakeCard) Capture(ctx context.Context, intent *models.PaymentIntent) error {
+	if intent.Status != models.PaymentAuthorized {
+		return ErrInvalidPaymentState
+	}
+	if strings.HasPrefix(intent.ProviderRef, fakeAsyncChargePrefix) {
+		return ErrPaymentPending
+	}
+	intent.Status = models.PaymentCaptured
+	return nil
+}
+
+func (*FakeCard) Refund(ctx context.Context, intent *models.PaymentIntent, amount uint64, reference string) error {
+	return refund(intent, amount)
+}
+
+func (*FakeCard) Void(ctx context.Context, intent *models.PaymentIntent) error {
+	if intent.Status != models.PaymentPending && intent.Status != models.PaymentAuthorized {
+		return ErrInvalidPaymentState
+	}
+	intent.Status = models.PaymentVoided
+	return nil
+}
+
+// VerifyWebhook expects signature in the form t=<unix time>,v1=<hex>, where
+// the hex is the HMAC-SHA256 of "<unix time>.<payload>" keyed with the webhook
+// secret. Signatures older than WebhookTolerance are rejected so that captured
+// requests cannot be replayed later.
+func (p *FakeCard) VerifyWebhook(payload []byte, signature string) (models.PaymentEvent, error) {
+	var event models.PaymentEvent
+	if len(p.webhookSecret) == 0 {
+		return event, ErrInvalidSignature
+	}
+	var timestamp, digest string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			digest = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return event, ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil || !hmac.Equal(fakeCardSignature(p.webhookSecret, unix, payload), expected) {
+		return event, ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return event, ErrStaleWebhook
+	}
+	if err = json.Unmarshal(payload, &event); err != nil || event.EventId == "" || event.ProviderRef == "" {
+		return event, ErrInvalidWebhookPayload
+	}
+	event.Provider = p.Name()
+	return event, nil
+}
+
+// SignFakeCardWebhook signs payload the way the fake card provider does, for
+// tools that simulate its webhooks.
+func SignFakeCardWebhook(secret string, payload []byte, at time.Time) string {
+	unix := at.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", unix, hex.EncodeToString(fakeCardSignature([]byte(secret), unix, payload)))
+}
+
+func fakeCardSignature(secret []byte, unix int64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(unix, 10) + "."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
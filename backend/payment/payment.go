@@ -0,0 +1,135 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/models"
+)
+
+var (
+	ErrUnknownProvider       = errors.New("payment method is not supported")
+	ErrDeclined              = errors.New("payment was declined")
+	ErrInvalidPaymentState   = errors.New("payment cannot be changed in its current state")
+	ErrRefundExceedsPayment  = errors.New("refund exceeds the captured amount")
+	ErrWebhookNotSupported   = errors.New("provider does not send webhooks")
+	ErrInvalidWebhookPayload = errors.New("webhook payload is not valid")
+	ErrInvalidSignature      = errors.New("webhook signature is not valid")
+	ErrStaleWebhook          = errors.New("webhook timestamp is outside the tolerance")
+	ErrPaymentPending        = errors.New("payment is waiting for the provider to confirm it")
+)
+
+// WebhookTolerance is how far the timestamp of a signed webhook may be from
+// the time it is received.
+const WebhookTolerance = 5 * time.Minute
+
+// Provider moves money for orders through one payment method. Every method
+// updates the intent it is given, which the caller then saves.
+type Provider interface {
+	Name() string
+	// DeferredCapture reports whether payments are captured when the order is
+	// delivered rather than when it is placed.
+	DeferredCapture() bool
+	// Authorize reserves the intent's amount from source, a method specific
+	// reference such as a card token.
+	Authorize(ctx context.Context, intent *models.PaymentIntent, source string) error
+	// Capture takes the authorized amount. Providers that confirm captures
+	// asynchronously return ErrPaymentPending and report the outcome in a
+	// webhook.
+	Capture(ctx context.Context, intent *models.PaymentIntent) error
+	// Refund pays back amount of a captured payment. Reference identifies the
+	// refund so that repeating it does not pay twice.
+	Refund(ctx context.Context, intent *models.PaymentIntent, amount uint64, reference string) error
+	Void(ctx context.Context, intent *models.PaymentIntent) error
+	// VerifyWebhook checks that payload was sent by the provider and decodes
+	// the payment event it carries.
+	VerifyWebhook(payload []byte, signature string) (models.PaymentEvent, error)
+}
+
+var providers = enabledProviders(os.Getenv("PAYMENT_PROVIDERS"))
+
+// enabledProviders builds the providers named in the comma separated list.
+// Cash on delivery is always available.
+func enabledProviders(names string) map[string]Provider {
+	enabled := map[string]Provider{models.PaymentCOD: COD{}}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case models.PaymentFakeCard:
+			enabled[models.PaymentFakeCard] = NewFakeCard(os.Getenv("FAKE_CARD_WEBHOOK_SECRET"))
+		}
+	}
+	return enabled
+}
+
+func Get(name string) (Provider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Methods lists the names of the enabled providers.
+func Methods() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// refund applies a refund of amount to the intent's refunded amount.
+func refund(intent *models.PaymentIntent, amount uint64) error {
+	if intent.Status != models.PaymentCaptured && intent.Status != models.PaymentPartiallyRefunded {
+		return ErrInvalidPaymentState
+	}
+	if intent.RefundedAmount+amount > intent.Amount {
+		return ErrRefundExceedsPayment
+	}
+	intent.RefundedAmount += amount
+	intent.Status = models.PaymentPartiallyRefunded
+	if intent.RefundedAmount == intent.Amount {
+		intent.Status = models.PaymentRefunded
+	}
+	return nil
+}
+
+// ApplyEvent updates the intent with the status a provider reported in event
+// and reports whether anything changed. Events that would move the payment
+// backwards, such as a capture arriving after a refund, are ignored, so that
+// events can be delivered in any order and more than once.
+func ApplyEvent(intent *models.PaymentIntent, event models.PaymentEvent) bool {
+	open := intent.Status == models.PaymentPending || intent.Status == models.PaymentAuthorized
+	switch event.Status {
+	case models.PaymentCaptured:
+		if !open {
+			return false
+		}
+	case models.PaymentFailed, models.PaymentVoided:
+		if !open {
+			return false
+		}
+		intent.FailureReason = event.FailureReason
+	case models.PaymentPartiallyRefunded, models.PaymentRefunded:
+		if intent.Status != models.PaymentCaptured && intent.Status != models.PaymentPartiallyRefunded {
+			return false
+		}
+		if event.Amount <= intent.RefundedAmount || event.Amount > intent.Amount {
+			return false
+		}
+		intent.RefundedAmount = event.Amount
+		event.Status = models.PaymentPartiallyRefunded
+		if intent.RefundedAmount == intent.Amount {
+			event.Status = models.PaymentRefunded
+		}
+	default:
+		return false
+	}
+	intent.Status = event.Status
+	return true
+}
@@ -0,0 +1,66 @@
+package pdf
+
+import "strings"
+
+// vietnamese maps the letters of Vietnamese that the standard fonts cannot
+// show to the letter without its marks. Letters in Latin-1 are kept as they
+// are.
+var vietnamese = map[rune]rune{}
+
+func init() {
+	groups := map[rune]string{
+		'a': "ảạăằắẳẵặầấẩẫậ", 'A': "ẢẠĂẰẮẲẴẶẦẤẨẪẬ",
+		'd': "đ", 'D': "Đ",
+		'e': "ẻẽẹềếểễệ", 'E': "ẺẼẸỀẾỂỄỆ",
+		'i': "ỉĩị", 'I': "ỈĨỊ",
+		'o': "ỏọồốổỗộơờớởỡợ", 'O': "ỎỌỒỐỔỖỘƠỜỚỞỠỢ",
+		'u': "ủũụưừứửữự", 'U': "ỦŨỤƯỪỨỬỮỰ",
+		'y': "ỳỷỹỵ", 'Y': "ỲỶỸỴ",
+	}
+	for base, letters := range groups {
+		for _, letter := range letters {
+			vietnamese[letter] = base
+		}
+	}
+}
+
+// encode converts s to the WinAnsi encoding of the standard fonts.
+func encode(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		if base, ok := vietnamese[r]; ok {
+			r = base
+		}
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			out.WriteByte(byte(r))
+		default:
+			out.WriteByte('?')
+		}
+	}
+	return out.String()
+}
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// Helvetica, in thousandths of the font size.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// TextWidth is how wide s is in Helvetica at size.
+func TextWidth(s string, size float64) float64 {
+	var width int
+	for _, c := range []byte(encode(s)) {
+		if c >= 32 && c < 127 {
+			width += helveticaWidths[c-32]
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
@@ -0,0 +1,76 @@
+package pricing
+
+import (
+	"errors"
+
+	"backend/models"
+)
+
+var (
+	ErrCouponMinSpend      = errors.New("cart does not reach the minimum spend of the coupon")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to any item in the cart")
+)
+
+// CouponDiscount is what coupon takes off the cart items once the applied
+// promotions have been taken off. The promotions are shared out over the lines
+// in proportion to their totals, as Calculate does. The minimum spend is
+// checked against the whole cart while the discount is worked out on the
+// eligible lines only, and never exceeds them.
+func CouponDiscount(coupon models.Coupon, items []models.Product, promotions []models.AppliedPromotion) (uint64, error) {
+	var subtotal, eligible, promoted uint64
+	for _, item := range items {
+		total := item.Price * item.CartQuantity()
+		subtotal += total
+		if couponCovers(coupon, item) {
+			eligible += total
+		}
+	}
+	for _, promotion := range promotions {
+		promoted += promotion.Discount
+	}
+	if promoted > subtotal {
+		promoted = subtotal
+	}
+	if subtotal > 0 {
+		eligible -= mulDiv(promoted, eligible, subtotal)
+	}
+	subtotal -= promoted
+	if subtotal < coupon.MinSpend {
+		return 0, ErrCouponMinSpend
+	}
+	if eligible == 0 {
+		return 0, ErrCouponNotApplicable
+	}
+
+	var discount uint64
+	switch coupon.Type {
+	case models.CouponPercentage:
+		discount = ApplyRate(eligible, coupon.Value)
+		if coupon.MaxDiscount > 0 && discount > coupon.MaxDiscount {
+			discount = coupon.MaxDiscount
+		}
+	case models.CouponFixed:
+		discount = coupon.Value
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount, nil
+}
+
+func couponCovers(coupon models.Coupon, item models.Product) bool {
+	if len(coupon.ProductIds) == 0 && len(coupon.Categories) == 0 {
+		return true
+	}
+	for _, id := range coupon.ProductIds {
+		if id == item.ProductId {
+			return true
+		}
+	}
+	for _, category := range coupon.Categories {
+		if category == item.Category {
+			return true
+		}
+	}
+	return false
+}
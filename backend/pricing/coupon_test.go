@@ -0,0 +1,126 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCouponDiscount(t *testing.T) {
+	cart := []models.Product{cartItem(productA, 100000, 2), cartItem(productB, 50000, 1)}
+	shoes := cartItem(productC, 40000, 1)
+	shoes.Category = "shoes"
+	promotion := []models.AppliedPromotion{{Discount: 50000}}
+	tests := []struct {
+		name       string
+		coupon     models.Coupon
+		items      []models.Product
+		promotions []models.AppliedPromotion
+		want       uint64
+		err        error
+	}{
+		{
+			name:   "percentage of the whole cart",
+			coupon: models.Coupon{Type: models.CouponPercentage, Value: 1000},
+			items:  cart,
+			want:   25000,
+		},
+		{
+			name:   "percentage capped by the maximum discount",
+			coupon: models.Coupon{Type: models.CouponPercentage, Value: 1000, MaxDiscount: 20000},
+			items:  cart,
+			want:   20000,
+		},
+		{
+			name:   "fixed amount",
+			coupon: models.Coupon{Type: models.CouponFixed, Value: 30000},
+			items:  cart,
+			want:   30000,
+		},
+		{
+			name:   "fixed amount never exceeds the eligible lines",
+			coupon: models.Coupon{Type: models.CouponFixed, Value: 80000, ProductIds: []primitive.ObjectID{productB}},
+			items:  cart,
+			want:   50000,
+		},
+		{
+			name:   "only the listed products",
+			coupon: models.Coupon{Type: models.CouponPercentage, Value: 1000, ProductIds: []primitive.ObjectID{productB}},
+			items:  cart,
+			want:   5000,
+		},
+		{
+			name:   "only the listed categories",
+			coupon: models.Coupon{Type: models.CouponPercentage, Value: 1000, Categories: []string{"shoes"}},
+			items:  append(append([]models.Product{}, cart...), shoes),
+			want:   4000,
+		},
+		{
+			name:   "minimum spend counts the whole cart",
+			coupon: models.Coupon{Type: models.CouponFixed, Value: 10000, MinSpend: 250000, ProductIds: []primitive.ObjectID{productB}},
+			items:  cart,
+			want:   10000,
+		},
+		{
+			name:   "minimum spend not reached",
+			coupon: models.Coupon{Type: models.CouponFixed, Value: 10000, MinSpend: 300000},
+			items:  cart,
+			err:    ErrCouponMinSpend,
+		},
+		{
+			name:   "no eligible line",
+			coupon: models.Coupon{Type: models.CouponFixed, Value: 10000, ProductIds: []primitive.ObjectID{productC}},
+			items:  cart,
+			err:    ErrCouponNotApplicable,
+		},
+		{
+			name:       "percentage of what promotions left",
+			coupon:     models.Coupon{Type: models.CouponPercentage, Value: 1000},
+			items:      cart,
+			promotions: promotion,
+			want:       20000,
+		},
+		{
+			name:       "promotions shared out over the eligible lines",
+			coupon:     models.Coupon{Type: models.CouponPercentage, Value: 1000, ProductIds: []primitive.ObjectID{productB}},
+			items:      cart,
+			promotions: promotion,
+			want:       4000,
+		},
+		{
+			name:       "fixed amount capped by what promotions left",
+			coupon:     models.Coupon{Type: models.CouponFixed, Value: 50000, ProductIds: []primitive.ObjectID{productB}},
+			items:      cart,
+			promotions: promotion,
+			want:       40000,
+		},
+		{
+			name:       "minimum spend after promotions",
+			coupon:     models.Coupon{Type: models.CouponFixed, Value: 10000, MinSpend: 220000},
+			items:      cart,
+			promotions: promotion,
+			err:        ErrCouponMinSpend,
+		},
+		{
+			name:       "nothing left after promotions",
+			coupon:     models.Coupon{Type: models.CouponFixed, Value: 10000},
+			items:      cart,
+			promotions: []models.AppliedPromotion{{Discount: 200000}, {Discount: 100000}},
+			err:        ErrCouponNotApplicable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CouponDiscount(test.coupon, test.items, test.promotions)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package pricing
+
+import (
+	"errors"
+	"math/bits"
+	"strings"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrAmountTooLarge = errors.New("amount is too large to be charged")
+
+// Options carries the order level adjustments applied on top of the line totals.
+type Options struct {
+	// Promotions and CouponDiscount make up the discount, which is taken off
+	// the subtotal and never exceeds it.
+	Promotions     []models.AppliedPromotion
+	CouponCode     string
+	CouponDiscount uint64
+	Shipping       uint64
+	// TaxRates are the rates to pick each line's tax from for Address.
+	TaxRates []models.TaxRate
+	Address  models.Address
+}
+
+// Calculate prices the given cart lines. Every amount is in the store currency,
+// which has no minor unit, so fractional amounts are rounded half up.
+//
+// The discount is shared out over the lines in proportion to their totals and
+// each line is taxed on what is left of it, at the rate of its tax class for
+// the address. Carts whose amounts do not fit in the currency's range are
+// refused with ErrAmountTooLarge.
+func Calculate(items []models.Product, opts Options) (models.PriceBreakdown, error) {
+	breakdown := models.PriceBreakdown{Lines: make([]models.OrderLine, 0, len(items))}
+	for _, item := range items {
+		line := models.OrderLine{
+			ProductId:   item.ProductId,
+			ProductName: item.ProductName,
+			UnitPrice:   item.Price,
+			Quantity:    item.CartQuantity(),
+			TaxClass:    item.TaxClass,
+		}
+		if line.TaxClass == "" {
+			line.TaxClass = models.TaxClassStandard
+		}
+		hi, total := bits.Mul64(line.UnitPrice, line.Quantity)
+		subtotal, carry := bits.Add64(breakdown.Subtotal, total, 0)
+		if hi != 0 || carry != 0 {
+			return breakdown, ErrAmountTooLarge
+		}
+		line.LineTotal = total
+		breakdown.Lines = append(breakdown.Lines, line)
+		breakdown.Subtotal = subtotal
+	}
+
+	breakdown.Promotions = opts.Promotions
+	for _, promotion := range opts.Promotions {
+		breakdown.Discount += promotion.Discount
+	}
+	breakdown.CouponCode = opts.CouponCode
+	breakdown.CouponDiscount = opts.CouponDiscount
+	breakdown.Discount += opts.CouponDiscount
+	if breakdown.Discount > breakdown.Subtotal {
+		breakdown.Discount = breakdown.Subtotal
+	}
+	allocateDiscount(breakdown.Lines, breakdown.Discount, breakdown.Subtotal)
+
+	for i := range breakdown.Lines {
+		line := &breakdown.Lines[i]
+		rate, ok := ResolveTaxRate(opts.TaxRates, line.TaxClass, opts.Address)
+		if !ok {
+			continue
+		}
+		taxable := line.LineTotal - line.Discount
+		line.TaxRate = rate.Rate
+		line.TaxInclusive = rate.Inclusive
+		if rate.Inclusive {
+			line.Tax = IncludedTax(taxable, rate.Rate)
+			breakdown.TaxIncluded += line.Tax
+		} else {
+			line.Tax = ApplyRate(taxable, rate.Rate)
+		}
+		breakdown.Tax += line.Tax
+	}
+
+	breakdown.Shipping = opts.Shipping
+	total, carry := bits.Add64(breakdown.Subtotal-breakdown.Discount-breakdown.TaxIncluded, breakdown.Shipping, 0)
+	total, carry2 := bits.Add64(total, breakdown.Tax, 0)
+	if carry|carry2 != 0 {
+		return breakdown, ErrAmountTooLarge
+	}
+	breakdown.Total = total
+	return breakdown, nil
+}
+
+// mulDiv is a * b / c rounded down, computed without overflowing. The result
+// must fit in 64 bits, which holds whenever b <= c.
+func mulDiv(a uint64, b uint64, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	quo, _ := bits.Div64(hi, lo, c)
+	return quo
+}
+
+// allocateDiscount shares discount out over the lines in proportion to their
+// totals. What rounding leaves over goes to the last line that can take it.
+func allocateDiscount(lines []models.OrderLine, discount uint64, subtotal uint64) {
+	if discount == 0 || subtotal == 0 {
+		return
+	}
+	var allocated uint64
+	for i := range lines {
+		lines[i].Discount = mulDiv(lines[i].LineTotal, discount, subtotal)
+		allocated += lines[i].Discount
+	}
+	for i := len(lines) - 1; i >= 0 && allocated < discount; i-- {
+		extra := lines[i].LineTotal - lines[i].Discount
+		if extra > discount-allocated {
+			extra = discount - allocated
+		}
+		lines[i].Discount += extra
+		allocated += extra
+	}
+}
+
+// ResolveTaxRate picks the most specific rate of the tax class that matches the
+// address. Cities and districts are compared ignoring case.
+func ResolveTaxRate(rates []models.TaxRate, taxClass string, address models.Address) (models.TaxRate, bool) {
+	var best models.TaxRate
+	bestScore := -1
+	for _, rate := range rates {
+		if rate.TaxClass != taxClass {
+			continue
+		}
+		if rate.City != "" && !sameRegion(rate.City, address.City) {
+			continue
+		}
+		if rate.District != "" && !sameRegion(rate.District, address.District) {
+			continue
+		}
+		score := 0
+		if rate.City != "" {
+			score++
+		}
+		if rate.District != "" {
+			score += 2
+		}
+		if score > bestScore {
+			best, bestScore = rate, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+func sameRegion(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// ApplyRate returns rate basis points of amount, rounded half up. Rates are
+// at most 100%.
+func ApplyRate(amount uint64, rate uint64) uint64 {
+	hi, lo := bits.Mul64(amount, rate)
+	lo, carry := bits.Add64(lo, 5000, 0)
+	quo, _ := bits.Div64(hi+carry, lo, 10000)
+	return quo
+}
+
+// IncludedTax is the tax contained in amount when amount already includes tax
+// at rate basis points, rounded half up.
+func IncludedTax(amount uint64, rate uint64) uint64 {
+	hi, lo := bits.Mul64(amount, 2*rate)
+	lo, carry := bits.Add64(lo, 10000+rate, 0)
+	quo, _ := bits.Div64(hi+carry, lo, 2*(10000+rate))
+	return quo
+}
+
+// RefundAmount is what the customer paid for the returned quantities of the
+// order's lines: their share of the line totals less their share of the
+// discount plus the tax charged on top of them. Shipping is not refunded.
+func RefundAmount(breakdown models.PriceBreakdown, returned map[primitive.ObjectID]uint64) uint64 {
+	if breakdown.Subtotal == 0 {
+		return 0
+	}
+	// Breakdowns from before discounts were shared out over the lines have
+	// their discount shared out here.
+	var allocated uint64
+	for _, line := range breakdown.Lines {
+		allocated += line.Discount
+	}
+	if allocated == 0 && breakdown.Discount > 0 {
+		lines := make([]models.OrderLine, len(breakdown.Lines))
+		copy(lines, breakdown.Lines)
+		allocateDiscount(lines, breakdown.Discount, breakdown.Subtotal)
+		breakdown.Lines = lines
+	}
+
+	var refund uint64
+	for _, line := range breakdown.Lines {
+		quantity := returned[line.ProductId]
+		if quantity > line.Quantity {
+			quantity = line.Quantity
+		}
+		if quantity == 0 {
+			continue
+		}
+		paid := line.LineTotal - line.Discount
+		if !line.TaxInclusive {
+			paid += line.Tax
+		}
+		refund += mulDiv(paid, quantity, line.Quantity)
+	}
+	return refund
+}
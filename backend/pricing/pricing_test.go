@@ -0,0 +1,325 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	productA = primitive.NewObjectID()
+	productB = primitive.NewObjectID()
+	productC = primitive.NewObjectID()
+)
+
+func cartItem(id primitive.ObjectID, price uint64, quantity uint64) models.Product {
+	return models.Product{ProductId: id, ProductName: id.Hex(), Price: price, Quantity: quantity}
+}
+
+func standardRate(rate uint64, inclusive bool) []models.TaxRate {
+	return []models.TaxRate{{TaxClass: models.TaxClassStandard, Rate: rate, Inclusive: inclusive}}
+}
+
+func TestCalculate(t *testing.T) {
+	cart := []models.Product{cartItem(productA, 100000, 2), cartItem(productB, 50000, 1)}
+	tests := []struct {
+		name          string
+		items         []models.Product
+		opts          Options
+		lineTotals    []uint64
+		lineDiscounts []uint64
+		lineTaxes     []uint64
+		subtotal      uint64
+		discount      uint64
+		tax           uint64
+		taxIncluded   uint64
+		total         uint64
+	}{
+		{
+			name:          "plain cart",
+			items:         cart,
+			lineTotals:    []uint64{200000, 50000},
+			lineDiscounts: []uint64{0, 0},
+			lineTaxes:     []uint64{0, 0},
+			subtotal:      250000,
+			total:         250000,
+		},
+		{
+			name:          "legacy line without quantity counts once",
+			items:         []models.Product{cartItem(productA, 70000, 0)},
+			lineTotals:    []uint64{70000},
+			lineDiscounts: []uint64{0},
+			lineTaxes:     []uint64{0},
+			subtotal:      70000,
+			total:         70000,
+		},
+		{
+			name:  "coupon and promotion shared out over lines",
+			items: cart,
+			opts: Options{
+				Promotions:     []models.AppliedPromotion{{Discount: 15000}},
+				CouponCode:     "SALE",
+				CouponDiscount: 10000,
+			},
+			lineTotals:    []uint64{200000, 50000},
+			lineDiscounts: []uint64{20000, 5000},
+			lineTaxes:     []uint64{0, 0},
+			subtotal:      250000,
+			discount:      25000,
+			total:         225000,
+		},
+		{
+			name:          "discount never exceeds subtotal",
+			items:         cart,
+			opts:          Options{CouponDiscount: 300000, Shipping: 30000},
+			lineTotals:    []uint64{200000, 50000},
+			lineDiscounts: []uint64{200000, 50000},
+			lineTaxes:     []uint64{0, 0},
+			subtotal:      250000,
+			discount:      250000,
+			total:         30000,
+		},
+		{
+			name:          "exclusive tax added with shipping",
+			items:         cart,
+			opts:          Options{CouponDiscount: 25000, Shipping: 30000, TaxRates: standardRate(1000, false)},
+			lineTotals:    []uint64{200000, 50000},
+			lineDiscounts: []uint64{20000, 5000},
+			lineTaxes:     []uint64{18000, 4500},
+			subtotal:      250000,
+			discount:      25000,
+			tax:           22500,
+			total:         277500,
+		},
+		{
+			name:          "inclusive tax shown but not added",
+			items:         cart,
+			opts:          Options{TaxRates: standardRate(1000, true)},
+			lineTotals:    []uint64{200000, 50000},
+			lineDiscounts: []uint64{0, 0},
+			lineTaxes:     []uint64{18182, 4545},
+			subtotal:      250000,
+			tax:           22727,
+			taxIncluded:   22727,
+			total:         250000,
+		},
+		{
+			name: "lines taxed by their own class",
+			items: []models.Product{
+				cartItem(productA, 100000, 1),
+				{ProductId: productB, Price: 100000, Quantity: 1, TaxClass: "reduced"},
+			},
+			opts: Options{TaxRates: []models.TaxRate{
+				{TaxClass: models.TaxClassStandard, Rate: 1000},
+				{TaxClass: "reduced", Rate: 500},
+			}},
+			lineTotals:    []uint64{100000, 100000},
+			lineDiscounts: []uint64{0, 0},
+			lineTaxes:     []uint64{10000, 5000},
+			subtotal:      200000,
+			tax:           15000,
+			total:         215000,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breakdown, err := Calculate(test.items, test.opts)
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			if len(breakdown.Lines) != len(test.lineTotals) {
+				t.Fatalf("got %d lines, want %d", len(breakdown.Lines), len(test.lineTotals))
+			}
+			for i, line := range breakdown.Lines {
+				if line.LineTotal != test.lineTotals[i] || line.Discount != test.lineDiscounts[i] || line.Tax != test.lineTaxes[i] {
+					t.Errorf("line %d: total %d discount %d tax %d, want %d %d %d", i, line.LineTotal, line.Discount, line.Tax, test.lineTotals[i], test.lineDiscounts[i], test.lineTaxes[i])
+				}
+			}
+			if breakdown.Subtotal != test.subtotal || breakdown.Discount != test.discount || breakdown.Tax != test.tax ||
+				breakdown.TaxIncluded != test.taxIncluded || breakdown.Total != test.total {
+				t.Errorf("subtotal %d discount %d tax %d included %d total %d, want %d %d %d %d %d",
+					breakdown.Subtotal, breakdown.Discount, breakdown.Tax, breakdown.TaxIncluded, breakdown.Total,
+					test.subtotal, test.discount, test.tax, test.taxIncluded, test.total)
+			}
+		})
+	}
+}
+
+func TestCalculateOverflow(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []models.Product
+		opts  Options
+	}{
+		{"line total", []models.Product{cartItem(productA, 1000, 18446744073709552)}, Options{}},
+		{"subtotal", []models.Product{cartItem(productA, math.MaxUint64, 1), cartItem(productB, 1, 1)}, Options{}},
+		{"total", []models.Product{cartItem(productA, math.MaxUint64, 1)}, Options{Shipping: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Calculate(test.items, test.opts); !errors.Is(err, ErrAmountTooLarge) {
+				t.Errorf("got %v, want ErrAmountTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestAllocateDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		totals   []uint64
+		discount uint64
+		want     []uint64
+	}{
+		{"proportional", []uint64{200000, 50000}, 25000, []uint64{20000, 5000}},
+		{"remainder to last line", []uint64{100, 100, 100}, 100, []uint64{33, 33, 34}},
+		{"remainder spills past a full line", []uint64{5, 1, 1}, 6, []uint64{4, 1, 1}},
+		{"whole subtotal", []uint64{7, 3}, 10, []uint64{7, 3}},
+		{"no discount", []uint64{100, 100}, 0, []uint64{0, 0}},
+		{"zero subtotal", []uint64{0, 0}, 50, []uint64{0, 0}},
+		{"large amounts", []uint64{math.MaxUint64 / 2, math.MaxUint64 / 2}, math.MaxUint64 / 2, []uint64{math.MaxUint64 / 4, math.MaxUint64/4 + 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := make([]models.OrderLine, len(test.totals))
+			var subtotal uint64
+			for i, total := range test.totals {
+				lines[i].LineTotal = total
+				subtotal += total
+			}
+			allocateDiscount(lines, test.discount, subtotal)
+			for i, line := range lines {
+				if line.Discount != test.want[i] {
+					t.Errorf("line %d: got %d, want %d", i, line.Discount, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRate(t *testing.T) {
+	tests := []struct {
+		amount, rate, want uint64
+	}{
+		{100000, 1000, 10000},
+		{15, 1000, 2},
+		{14, 1000, 1},
+		{0, 1000, 0},
+		{100000, 0, 0},
+		{100000, 10000, 100000},
+		{math.MaxUint64, 10000, math.MaxUint64},
+	}
+	for _, test := range tests {
+		if got := ApplyRate(test.amount, test.rate); got != test.want {
+			t.Errorf("ApplyRate(%d, %d) = %d, want %d", test.amount, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestIncludedTax(t *testing.T) {
+	tests := []struct {
+		amount, rate, want uint64
+	}{
+		{110000, 1000, 10000},
+		{200000, 1000, 18182},
+		{50000, 1000, 4545},
+		{11, 1000, 1},
+		{0, 1000, 0},
+		{100000, 0, 0},
+		{math.MaxUint64, 1000, 1676976733973595601},
+	}
+	for _, test := range tests {
+		if got := IncludedTax(test.amount, test.rate); got != test.want {
+			t.Errorf("IncludedTax(%d, %d) = %d, want %d", test.amount, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestRefundAmount(t *testing.T) {
+	taxed := models.PriceBreakdown{
+		Subtotal: 250000,
+		Discount: 25000,
+		Lines: []models.OrderLine{
+			{ProductId: productA, Quantity: 2, LineTotal: 200000, Discount: 20000, Tax: 18000},
+			{ProductId: productB, Quantity: 1, LineTotal: 50000, Discount: 5000, Tax: 4500},
+		},
+	}
+	inclusive := models.PriceBreakdown{
+		Subtotal: 200000,
+		Lines: []models.OrderLine{
+			{ProductId: productA, Quantity: 2, LineTotal: 200000, Tax: 18182, TaxInclusive: true},
+		},
+	}
+	legacy := models.PriceBreakdown{
+		Subtotal: 300,
+		Discount: 100,
+		Lines: []models.OrderLine{
+			{ProductId: productA, Quantity: 1, LineTotal: 100},
+			{ProductId: productB, Quantity: 1, LineTotal: 100},
+			{ProductId: productC, Quantity: 1, LineTotal: 100},
+		},
+	}
+	tests := []struct {
+		name      string
+		breakdown models.PriceBreakdown
+		returned  map[primitive.ObjectID]uint64
+		want      uint64
+	}{
+		{"one of two units with tax", taxed, map[primitive.ObjectID]uint64{productA: 1}, 99000},
+		{"whole order", taxed, map[primitive.ObjectID]uint64{productA: 2, productB: 1}, 247500},
+		{"more than ordered", taxed, map[primitive.ObjectID]uint64{productA: 5}, 198000},
+		{"product not on the order", taxed, map[primitive.ObjectID]uint64{productC: 1}, 0},
+		{"inclusive tax not added", inclusive, map[primitive.ObjectID]uint64{productA: 1}, 100000},
+		{"legacy discount shared out", legacy, map[primitive.ObjectID]uint64{productC: 1}, 66},
+		{"zero subtotal", models.PriceBreakdown{}, map[primitive.ObjectID]uint64{productA: 1}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := RefundAmount(test.breakdown, test.returned); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+	if legacy.Lines[2].Discount != 0 {
+		t.Errorf("RefundAmount changed the breakdown it was given")
+	}
+}
+
+func TestResolveTaxRate(t *testing.T) {
+	country := models.TaxRate{Name: "country", TaxClass: models.TaxClassStandard, Rate: 1000}
+	city := models.TaxRate{Name: "city", TaxClass: models.TaxClassStandard, City: "Hà Nội", Rate: 800}
+	district := models.TaxRate{Name: "district", TaxClass: models.TaxClassStandard, City: "Hà Nội", District: "Quận Ba Đình", Rate: 500}
+	anyCityDistrict := models.TaxRate{Name: "district in any city", TaxClass: models.TaxClassStandard, District: "Quận 1", Rate: 700}
+	reduced := models.TaxRate{Name: "reduced", TaxClass: "reduced", Rate: 500}
+	rates := []models.TaxRate{country, city, district, anyCityDistrict, reduced}
+	tests := []struct {
+		name     string
+		rates    []models.TaxRate
+		taxClass string
+		address  models.Address
+		want     string
+		found    bool
+	}{
+		{"district beats city", rates, models.TaxClassStandard, models.Address{City: "Hà Nội", District: "Quận Ba Đình"}, "district", true},
+		{"city beats country", rates, models.TaxClassStandard, models.Address{City: "Hà Nội", District: "Quận Hoàn Kiếm"}, "city", true},
+		{"district alone beats city", rates, models.TaxClassStandard, models.Address{City: "Hồ Chí Minh", District: "Quận 1"}, "district in any city", true},
+		{"country everywhere else", rates, models.TaxClassStandard, models.Address{City: "Đà Nẵng", District: "Quận Hải Châu"}, "country", true},
+		{"regions ignore case and spaces", rates, models.TaxClassStandard, models.Address{City: " hà nội ", District: "QUẬN BA ĐÌNH"}, "district", true},
+		{"other tax class", rates, "reduced", models.Address{City: "Hà Nội", District: "Quận Ba Đình"}, "reduced", true},
+		{"no rate for the class", rates, "luxury", models.Address{City: "Hà Nội"}, "", false},
+		{"no rate for the region", []models.TaxRate{city}, models.TaxClassStandard, models.Address{City: "Đà Nẵng"}, "", false},
+		{"no rates", nil, models.TaxClassStandard, models.Address{City: "Hà Nội"}, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rate, found := ResolveTaxRate(test.rates, test.taxClass, test.address)
+			if found != test.found || rate.Name != test.want {
+				t.Errorf("got %q %v, want %q %v", rate.Name, found, test.want, test.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package pricing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// lot is a number of units of one product that no promotion has discounted
+// yet.
+type lot struct {
+	productId primitive.ObjectID
+	category  string
+	price     uint64
+	count     uint64
+}
+
+// ApplyPromotions evaluates the promotions against the cart items at now and
+// returns the ones that apply, in the order they were applied. The rules of
+// models.Promotion decide the order and which promotions combine.
+func ApplyPromotions(promotions []models.Promotion, items []models.Product, now time.Time) []models.AppliedPromotion {
+	ordered := make([]models.Promotion, len(promotions))
+	copy(ordered, promotions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+
+	// Lots are kept most expensive first, so that free units are the cheap
+	// ones.
+	available := make([]lot, 0, len(items))
+	var subtotal uint64
+	for _, item := range items {
+		available = append(available, lot{productId: item.ProductId, category: item.Category, price: item.Price, count: item.CartQuantity()})
+		subtotal += item.Price * item.CartQuantity()
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].price > available[j].price
+	})
+
+	applied := make([]models.AppliedPromotion, 0)
+	var discounted uint64
+	for _, promotion := range ordered {
+		if !promotionRunning(promotion, now) {
+			continue
+		}
+		if !promotion.Stackable && len(applied) > 0 {
+			continue
+		}
+		var result models.AppliedPromotion
+		var remaining []lot
+		switch promotion.Type {
+		case models.PromotionBuyXGetY:
+			result, remaining = buyXGetY(promotion, available)
+		case models.PromotionBundle:
+			result, remaining = bundle(promotion, available)
+		case models.PromotionTiered:
+			result, remaining = tiered(promotion, subtotal-discounted), available
+		}
+		if result.Discount == 0 {
+			continue
+		}
+		if result.Discount > subtotal-discounted {
+			result.Discount = subtotal - discounted
+		}
+		result.PromotionId = promotion.PromotionId
+		result.Name = promotion.Name
+		result.Type = promotion.Type
+		applied = append(applied, result)
+		discounted += result.Discount
+		available = remaining
+		if !promotion.Stackable {
+			break
+		}
+	}
+	return applied
+}
+
+// CombinesWithCoupons reports whether a coupon may be used on top of the
+// applied promotions.
+func CombinesWithCoupons(promotions []models.Promotion, applied []models.AppliedPromotion) bool {
+	combines := make(map[primitive.ObjectID]bool, len(promotions))
+	for _, promotion := range promotions {
+		combines[promotion.PromotionId] = promotion.CombinesWithCoupons
+	}
+	for _, promotion := range applied {
+		if !combines[promotion.PromotionId] {
+			return false
+		}
+	}
+	return true
+}
+
+func promotionRunning(promotion models.Promotion, now time.Time) bool {
+	if !promotion.Active {
+		return false
+	}
+	if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+		return false
+	}
+	return promotion.EndsAt == nil || !now.After(*promotion.EndsAt)
+}
+
+func promotionCovers(promotion models.Promotion, l lot) bool {
+	if len(promotion.ProductIds) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	for _, id := range promotion.ProductIds {
+		if id == l.productId {
+			return true
+		}
+	}
+	for _, category := range promotion.Categories {
+		if category == l.category {
+			return true
+		}
+	}
+	return false
+}
+
+// buyXGetY lines the eligible units up most expensive first and splits them
+// into groups of BuyQuantity+FreeQuantity. The last FreeQuantity units of each
+// complete group, its cheapest, are free.
+func buyXGetY(promotion models.Promotion, available []lot) (models.AppliedPromotion, []lot) {
+	var result models.AppliedPromotion
+	group := promotion.BuyQuantity + promotion.FreeQuantity
+	if promotion.BuyQuantity == 0 || promotion.FreeQuantity == 0 {
+		return result, available
+	}
+	var eligible uint64
+	for _, l := range available {
+		if promotionCovers(promotion, l) {
+			eligible += l.count
+		}
+	}
+	grouped := eligible / group * group
+	if grouped == 0 {
+		return result, available
+	}
+
+	// freeBefore counts the free units among the first n grouped units.
+	freeBefore := func(n uint64) uint64 {
+		free := n / group * promotion.FreeQuantity
+		if n%group > promotion.BuyQuantity {
+			free += n%group - promotion.BuyQuantity
+		}
+		return free
+	}
+	remaining := make([]lot, 0, len(available))
+	var position, free uint64
+	for _, l := range available {
+		if !promotionCovers(promotion, l) || position >= grouped {
+			remaining = append(remaining, l)
+			continue
+		}
+		taken := l.count
+		if position+taken > grouped {
+			taken = grouped - position
+		}
+		freeUnits := freeBefore(position+taken) - freeBefore(position)
+		result.Discount += freeUnits * l.price
+		free += freeUnits
+		position += taken
+		if l.count > taken {
+			l.count -= taken
+			remaining = append(remaining, l)
+		}
+	}
+	result.Description = fmt.Sprintf("Buy %d get %d free: %d free unit(s)", promotion.BuyQuantity, promotion.FreeQuantity, free)
+	return result, remaining
+}
+
+// bundle sells as many complete bundles as the cart holds for the bundle
+// price each. A product listed more than once in the bundle needs the sum of
+// its quantities.
+func bundle(promotion models.Promotion, available []lot) (models.AppliedPromotion, []lot) {
+	var result models.AppliedPromotion
+	if len(promotion.BundleItems) == 0 {
+		return result, available
+	}
+	required := make(map[primitive.ObjectID]uint64)
+	for _, item := range promotion.BundleItems {
+		if item.Quantity == 0 {
+			return result, available
+		}
+		required[item.ProductId] += item.Quantity
+	}
+	counts := make(map[primitive.ObjectID]uint64)
+	prices := make(map[primitive.ObjectID]uint64)
+	for _, l := range available {
+		counts[l.productId] += l.count
+		prices[l.productId] = l.price
+	}
+	var bundles uint64
+	first := true
+	for productId, quantity := range required {
+		fits := counts[productId] / quantity
+		if first || fits < bundles {
+			bundles = fits
+		}
+		first = false
+	}
+	if bundles == 0 {
+		return result, available
+	}
+
+	used := make(map[primitive.ObjectID]uint64)
+	var regular uint64
+	for productId, quantity := range required {
+		used[productId] = bundles * quantity
+		regular += bundles * quantity * prices[productId]
+	}
+	if regular <= promotion.BundlePrice*bundles {
+		return result, available
+	}
+	remaining := make([]lot, 0, len(available))
+	for _, l := range available {
+		taken := used[l.productId]
+		if taken > l.count {
+			taken = l.count
+		}
+		used[l.productId] -= taken
+		if l.count > taken {
+			l.count -= taken
+			remaining = append(remaining, l)
+		}
+	}
+	result.Discount = regular - promotion.BundlePrice*bundles
+	result.Description = fmt.Sprintf("%d bundle(s) at %d each", bundles, promotion.BundlePrice)
+	return result, remaining
+}
+
+// tiered takes the best tier that amount reaches off it.
+func tiered(promotion models.Promotion, amount uint64) models.AppliedPromotion {
+	var result models.AppliedPromotion
+	for _, tier := range promotion.Tiers {
+		if amount < tier.MinSubtotal {
+			continue
+		}
+		discount := tier.Amount
+		description := fmt.Sprintf("%d off orders from %d", tier.Amount, tier.MinSubtotal)
+		if tier.Rate > 0 {
+			discount = ApplyRate(amount, tier.Rate)
+			description = fmt.Sprintf("%d.%02d%% off orders from %d", tier.Rate/100, tier.Rate%100, tier.MinSubtotal)
+		}
+		if discount > result.Discount {
+			result.Discount = discount
+			result.Description = description
+		}
+	}
+	return result
+}
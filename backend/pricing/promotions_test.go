@@ -0,0 +1,177 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func promotion(promotionType models.PromotionType, priority int, stackable bool) models.Promotion {
+	return models.Promotion{PromotionId: primitive.NewObjectID(), Type: promotionType, Priority: priority, Stackable: stackable, Active: true}
+}
+
+func buyXGetYPromotion(buy uint64, free uint64, products ...primitive.ObjectID) models.Promotion {
+	p := promotion(models.PromotionBuyXGetY, 0, false)
+	p.BuyQuantity, p.FreeQuantity, p.ProductIds = buy, free, products
+	return p
+}
+
+func bundlePromotion(price uint64, items ...models.BundleItem) models.Promotion {
+	p := promotion(models.PromotionBundle, 0, false)
+	p.BundlePrice, p.BundleItems = price, items
+	return p
+}
+
+func tieredPromotion(priority int, stackable bool, tiers ...models.PromotionTier) models.Promotion {
+	p := promotion(models.PromotionTiered, priority, stackable)
+	p.Tiers = tiers
+	return p
+}
+
+func TestApplyPromotions(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+	cart := []models.Product{cartItem(productA, 100000, 2), cartItem(productB, 50000, 1)}
+
+	stackedBuy := buyXGetYPromotion(2, 1)
+	stackedBuy.Priority, stackedBuy.Stackable = 2, true
+	firstBuy := buyXGetYPromotion(1, 1)
+	firstBuy.Priority, firstBuy.Stackable = 2, true
+	secondBuy := buyXGetYPromotion(1, 1)
+	secondBuy.Priority, secondBuy.Stackable = 1, true
+	inactive := tieredPromotion(0, true, models.PromotionTier{Amount: 10000})
+	inactive.Active = false
+	notStarted := tieredPromotion(0, true, models.PromotionTier{Amount: 10000})
+	notStarted.StartsAt = &tomorrow
+	ended := tieredPromotion(0, true, models.PromotionTier{Amount: 10000})
+	ended.EndsAt = &yesterday
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		items      []models.Product
+		want       []uint64
+	}{
+		{
+			name:       "buy two get the cheapest free",
+			promotions: []models.Promotion{buyXGetYPromotion(2, 1)},
+			items:      cart,
+			want:       []uint64{50000},
+		},
+		{
+			name:       "buy x get y on listed products only",
+			promotions: []models.Promotion{buyXGetYPromotion(2, 1, productB)},
+			items:      []models.Product{cartItem(productA, 100000, 2), cartItem(productB, 50000, 3)},
+			want:       []uint64{50000},
+		},
+		{
+			name:       "buy x get y without a complete group",
+			promotions: []models.Promotion{buyXGetYPromotion(3, 1)},
+			items:      cart,
+			want:       []uint64{},
+		},
+		{
+			name:       "bundle",
+			promotions: []models.Promotion{bundlePromotion(120000, models.BundleItem{ProductId: productA, Quantity: 1}, models.BundleItem{ProductId: productB, Quantity: 1})},
+			items:      cart,
+			want:       []uint64{30000},
+		},
+		{
+			name:       "bundle listing a product twice needs both units",
+			promotions: []models.Promotion{bundlePromotion(200000, models.BundleItem{ProductId: productA, Quantity: 1}, models.BundleItem{ProductId: productA, Quantity: 1}, models.BundleItem{ProductId: productB, Quantity: 1})},
+			items:      []models.Product{cartItem(productA, 100000, 1), cartItem(productB, 50000, 1)},
+			want:       []uint64{},
+		},
+		{
+			name:       "bundle listing a product twice",
+			promotions: []models.Promotion{bundlePromotion(200000, models.BundleItem{ProductId: productA, Quantity: 1}, models.BundleItem{ProductId: productA, Quantity: 1}, models.BundleItem{ProductId: productB, Quantity: 1})},
+			items:      cart,
+			want:       []uint64{50000},
+		},
+		{
+			name:       "bundle dearer than its items",
+			promotions: []models.Promotion{bundlePromotion(160000, models.BundleItem{ProductId: productA, Quantity: 1}, models.BundleItem{ProductId: productB, Quantity: 1})},
+			items:      cart,
+			want:       []uint64{},
+		},
+		{
+			name: "best tier reached",
+			promotions: []models.Promotion{tieredPromotion(0, false,
+				models.PromotionTier{MinSubtotal: 200000, Amount: 10000},
+				models.PromotionTier{MinSubtotal: 250000, Rate: 1000},
+				models.PromotionTier{MinSubtotal: 300000, Amount: 50000},
+			)},
+			items: cart,
+			want:  []uint64{25000},
+		},
+		{
+			name:       "stackable promotions apply in priority order",
+			promotions: []models.Promotion{tieredPromotion(1, true, models.PromotionTier{MinSubtotal: 200000, Rate: 1000}), stackedBuy},
+			items:      cart,
+			want:       []uint64{50000, 20000},
+		},
+		{
+			name:       "units are discounted once",
+			promotions: []models.Promotion{secondBuy, firstBuy},
+			items:      cart,
+			want:       []uint64{100000},
+		},
+		{
+			name:       "promotion that is not stackable stops the rest",
+			promotions: []models.Promotion{tieredPromotion(1, true, models.PromotionTier{Amount: 5000}), tieredPromotion(2, false, models.PromotionTier{Amount: 10000})},
+			items:      cart,
+			want:       []uint64{10000},
+		},
+		{
+			name:       "promotion that is not stackable skipped after another applied",
+			promotions: []models.Promotion{tieredPromotion(2, true, models.PromotionTier{Amount: 5000}), tieredPromotion(1, false, models.PromotionTier{Amount: 10000})},
+			items:      cart,
+			want:       []uint64{5000},
+		},
+		{
+			name:       "discount never exceeds the subtotal",
+			promotions: []models.Promotion{tieredPromotion(0, false, models.PromotionTier{Amount: 400000})},
+			items:      cart,
+			want:       []uint64{250000},
+		},
+		{
+			name:       "inactive and out of date promotions",
+			promotions: []models.Promotion{inactive, notStarted, ended},
+			items:      cart,
+			want:       []uint64{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			applied := ApplyPromotions(test.promotions, test.items, now)
+			if len(applied) != len(test.want) {
+				t.Fatalf("got %d promotions, want %d: %+v", len(applied), len(test.want), applied)
+			}
+			for i, promotion := range applied {
+				if promotion.Discount != test.want[i] {
+					t.Errorf("promotion %d: got %d, want %d", i, promotion.Discount, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCombinesWithCoupons(t *testing.T) {
+	combining := tieredPromotion(0, true, models.PromotionTier{Amount: 5000})
+	combining.CombinesWithCoupons = true
+	exclusive := tieredPromotion(0, true, models.PromotionTier{Amount: 5000})
+	promotions := []models.Promotion{combining, exclusive}
+
+	if !CombinesWithCoupons(promotions, nil) {
+		t.Errorf("no applied promotion should combine with coupons")
+	}
+	if !CombinesWithCoupons(promotions, []models.AppliedPromotion{{PromotionId: combining.PromotionId}}) {
+		t.Errorf("promotion combining with coupons refused the coupon")
+	}
+	if CombinesWithCoupons(promotions, []models.AppliedPromotion{{PromotionId: combining.PromotionId}, {PromotionId: exclusive.PromotionId}}) {
+		t.Errorf("promotion not combining with coupons allowed the coupon")
+	}
+}
@@ -0,0 +1,115 @@
+package pricing
+
+import (
+	"errors"
+
+	"backend/models"
+)
+
+var (
+	ErrNoShippingZone      = errors.New("we do not deliver to this address")
+	ErrDeliveryUnavailable = errors.New("delivery option is not available for this order")
+)
+
+// ResolveZone picks the zone that serves the address: the one naming its
+// district, else the one naming its city, else one without regions.
+func ResolveZone(zones []models.ShippingZone, address models.Address) (models.ShippingZone, bool) {
+	var best models.ShippingZone
+	bestScore := -1
+	for _, zone := range zones {
+		score := -1
+		if len(zone.Regions) == 0 {
+			score = 0
+		}
+		for _, region := range zone.Regions {
+			if !sameRegion(region.City, address.City) {
+				continue
+			}
+			if region.District == "" && score < 1 {
+				score = 1
+			} else if region.District != "" && sameRegion(region.District, address.District) {
+				score = 2
+			}
+		}
+		if score > bestScore {
+			best, bestScore = zone, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// CartWeight is the weight of the items in grams.
+func CartWeight(items []models.Product) uint64 {
+	var weight uint64
+	for _, item := range items {
+		weight += item.Weight * item.CartQuantity()
+	}
+	return weight
+}
+
+// QuoteShipping lists what every delivery option of the zone serving the
+// address costs for the items, whose goods are worth goodsValue after
+// discounts. Options whose brackets do not reach the order are left out.
+// Options priced by a carrier are quoted at no cost, for the caller to ask the
+// carrier for theirs.
+func QuoteShipping(zones []models.ShippingZone, address models.Address, items []models.Product, goodsValue uint64) ([]models.ShippingQuote, error) {
+	zone, ok := ResolveZone(zones, address)
+	if !ok {
+		return nil, ErrNoShippingZone
+	}
+	weight := CartWeight(items)
+	quotes := make([]models.ShippingQuote, 0, len(zone.Rates))
+	for _, rate := range zone.Rates {
+		var cost uint64
+		if rate.Carrier == "" {
+			measure := goodsValue
+			if rate.Basis == models.ShippingByWeight {
+				measure = weight
+			}
+			if cost, ok = bracketPrice(rate.Brackets, measure); !ok {
+				continue
+			}
+		}
+		quote := models.ShippingQuote{
+			Option:   rate.Option,
+			Carrier:  rate.Carrier,
+			ZoneId:   zone.ZoneId,
+			ZoneName: zone.Name,
+			Cost:     cost,
+			MinDays:  rate.MinDays,
+			MaxDays:  rate.MaxDays,
+		}
+		if rate.FreeOver > 0 && goodsValue >= rate.FreeOver {
+			quote.Cost = 0
+			quote.Free = true
+		}
+		quotes = append(quotes, quote)
+	}
+	return quotes, nil
+}
+
+// ChooseDelivery picks the quote of the delivery option.
+func ChooseDelivery(quotes []models.ShippingQuote, option string) (models.ShippingQuote, error) {
+	for _, quote := range quotes {
+		if quote.Option == option {
+			return quote, nil
+		}
+	}
+	return models.ShippingQuote{}, ErrDeliveryUnavailable
+}
+
+// bracketPrice is the price of the smallest bracket that covers measure.
+func bracketPrice(brackets []models.RateBracket, measure uint64) (uint64, bool) {
+	var price uint64
+	var bound uint64
+	found := false
+	for _, bracket := range brackets {
+		if bracket.UpTo != 0 && bracket.UpTo < measure {
+			continue
+		}
+		if !found || (bracket.UpTo != 0 && (bound == 0 || bracket.UpTo < bound)) {
+			price, bound, found = bracket.Price, bracket.UpTo, true
+		}
+	}
+	return price, found
+}
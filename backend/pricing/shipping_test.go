@@ -0,0 +1,163 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"backend/models"
+)
+
+func weighed(product models.Product, weight uint64) models.Product {
+	product.Weight = weight
+	return product
+}
+
+func TestQuoteShipping(t *testing.T) {
+	city := models.ShippingZone{
+		Name:    "Hà Nội",
+		Regions: []models.ShippingRegion{{City: "Hà Nội"}},
+		Rates: []models.ShippingRate{
+			{
+				Option:   models.DeliveryStandard,
+				Basis:    models.ShippingByWeight,
+				Brackets: []models.RateBracket{{UpTo: 0, Price: 60000}, {UpTo: 5000, Price: 35000}, {UpTo: 1000, Price: 20000}},
+				FreeOver: 500000,
+			},
+			{
+				Option:   models.DeliveryExpress,
+				Basis:    models.ShippingByOrderValue,
+				Brackets: []models.RateBracket{{UpTo: 300000, Price: 50000}, {UpTo: 0, Price: 30000}},
+			},
+		},
+	}
+	district := models.ShippingZone{
+		Name:    "Ba Đình",
+		Regions: []models.ShippingRegion{{City: "Hà Nội", District: "Quận Ba Đình"}},
+		Rates:   []models.ShippingRate{{Option: models.DeliveryStandard, Basis: models.ShippingByWeight, Brackets: []models.RateBracket{{Price: 15000}}}},
+	}
+	rest := models.ShippingZone{
+		Name: "Everywhere else",
+		Rates: []models.ShippingRate{
+			{Option: models.DeliveryStandard, Basis: models.ShippingByWeight, Brackets: []models.RateBracket{{UpTo: 2000, Price: 40000}}},
+			{Option: models.DeliveryExpress, Carrier: "ghn"},
+		},
+	}
+	zones := []models.ShippingZone{rest, city, district}
+	hoanKiem := models.Address{City: "Hà Nội", District: "Quận Hoàn Kiếm"}
+	light := []models.Product{weighed(cartItem(productA, 100000, 2), 400)}
+
+	type quote struct {
+		option string
+		cost   uint64
+		free   bool
+	}
+	tests := []struct {
+		name       string
+		zones      []models.ShippingZone
+		address    models.Address
+		items      []models.Product
+		goodsValue uint64
+		zone       string
+		want       []quote
+		err        error
+	}{
+		{
+			name:       "city zone by weight and by value",
+			zones:      zones,
+			address:    hoanKiem,
+			items:      light,
+			goodsValue: 200000,
+			zone:       "Hà Nội",
+			want:       []quote{{models.DeliveryStandard, 20000, false}, {models.DeliveryExpress, 50000, false}},
+		},
+		{
+			name:       "weight on a bracket bound",
+			zones:      zones,
+			address:    hoanKiem,
+			items:      []models.Product{weighed(cartItem(productA, 100000, 2), 500)},
+			goodsValue: 300000,
+			zone:       "Hà Nội",
+			want:       []quote{{models.DeliveryStandard, 20000, false}, {models.DeliveryExpress, 50000, false}},
+		},
+		{
+			name:       "weight above every bound",
+			zones:      zones,
+			address:    hoanKiem,
+			items:      []models.Product{weighed(cartItem(productA, 100000, 2), 500), weighed(cartItem(productB, 50000, 10), 1000)},
+			goodsValue: 300001,
+			zone:       "Hà Nội",
+			want:       []quote{{models.DeliveryStandard, 60000, false}, {models.DeliveryExpress, 30000, false}},
+		},
+		{
+			name:       "free over a goods value",
+			zones:      zones,
+			address:    hoanKiem,
+			items:      light,
+			goodsValue: 500000,
+			zone:       "Hà Nội",
+			want:       []quote{{models.DeliveryStandard, 0, true}, {models.DeliveryExpress, 30000, false}},
+		},
+		{
+			name:       "district zone beats city zone",
+			zones:      zones,
+			address:    models.Address{City: "hà nội", District: "Quận Ba Đình"},
+			items:      light,
+			goodsValue: 200000,
+			zone:       "Ba Đình",
+			want:       []quote{{models.DeliveryStandard, 15000, false}},
+		},
+		{
+			name:       "zone without regions covers everywhere else",
+			zones:      zones,
+			address:    models.Address{City: "Đà Nẵng", District: "Quận Hải Châu"},
+			items:      light,
+			goodsValue: 200000,
+			zone:       "Everywhere else",
+			want:       []quote{{models.DeliveryStandard, 40000, false}, {models.DeliveryExpress, 0, false}},
+		},
+		{
+			name:       "option whose brackets do not reach the order is left out",
+			zones:      zones,
+			address:    models.Address{City: "Đà Nẵng", District: "Quận Hải Châu"},
+			items:      []models.Product{weighed(cartItem(productA, 100000, 5), 500)},
+			goodsValue: 500000,
+			zone:       "Everywhere else",
+			want:       []quote{{models.DeliveryExpress, 0, false}},
+		},
+		{
+			name:    "no zone serves the address",
+			zones:   []models.ShippingZone{city, district},
+			address: models.Address{City: "Đà Nẵng"},
+			items:   light,
+			err:     ErrNoShippingZone,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quotes, err := QuoteShipping(test.zones, test.address, test.items, test.goodsValue)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if len(quotes) != len(test.want) {
+				t.Fatalf("got %d quotes, want %d: %+v", len(quotes), len(test.want), quotes)
+			}
+			for i, got := range quotes {
+				if got.ZoneName != test.zone || got.Option != test.want[i].option || got.Cost != test.want[i].cost || got.Free != test.want[i].free {
+					t.Errorf("quote %d: got %s %s %d %v, want %s %s %d %v", i, got.ZoneName, got.Option, got.Cost, got.Free,
+						test.zone, test.want[i].option, test.want[i].cost, test.want[i].free)
+				}
+			}
+		})
+	}
+}
+
+func TestChooseDelivery(t *testing.T) {
+	quotes := []models.ShippingQuote{{Option: models.DeliveryStandard, Cost: 20000}, {Option: models.DeliveryExpress, Cost: 50000}}
+	quote, err := ChooseDelivery(quotes, models.DeliveryExpress)
+	if err != nil || quote.Cost != 50000 {
+		t.Errorf("got %+v %v, want the express quote", quote, err)
+	}
+	if _, err = ChooseDelivery(quotes[:1], models.DeliveryExpress); !errors.Is(err, ErrDeliveryUnavailable) {
+		t.Errorf("got %v, want ErrDeliveryUnavailable", err)
+	}
+}
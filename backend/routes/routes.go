@@ -9,27 +9,86 @@ import (
 )
 
 func Routes(router *gin.Engine) {
-	app := controllers.NewApplication(database.ProductData(database.Client, "Products"), database.UserData(database.Client, "Users"), database.UserData(database.Client, "Orders"))
+	app := controllers.NewApplication(database.ProductData(database.Client, "Products"), database.UserData(database.Client, "Users"), database.UserData(database.Client, "Orders"), database.PaymentData(database.Client, "PaymentIntents"), database.CounterData(database.Client, "Counters"), controllers.Pricer)
 
 	router.POST("/user/sign-up", controllers.SignUp())
 	router.POST("/user/log-in", controllers.LogIn())
 	router.GET("/user/view-products", controllers.GetAllProducts())
 	router.GET("/user/search", controllers.SearchProductByQuery())
+	router.GET("/user/payment-methods", controllers.GetPaymentMethods())
+	router.GET("/divisions/provinces", controllers.GetProvinces())
+	router.GET("/divisions/provinces/:code/districts", controllers.GetDistricts())
+	router.GET("/divisions/districts/:code/wards", controllers.GetWards())
 
-	router.GET("/admin/view-orders", controllers.GetAllOrders())
-	router.POST("/admin/add-product", controllers.ProductAdderAdmin())
-	router.PATCH("/admin/update-product", controllers.ProductUpdaterAdmin())
+	router.GET("/guest/list-cart", controllers.GetItemsFromGuestCart())
+	router.PATCH("/guest/add-to-cart", controllers.AddToGuestCart())
+	router.PATCH("/guest/remove-item", controllers.RemoveFromGuestCart())
+
+	router.POST("/payments/webhook/:provider", controllers.PaymentWebhook())
+
+	admin := router.Group("/admin", middleware.Authorization(), middleware.AdminOnly())
+	admin.PATCH("/bulk-update-order-status", controllers.BulkUpdateOrderStatus())
+	admin.GET("/orders/:id", controllers.GetOrder())
+	admin.GET("/orders/:id/invoice", controllers.GetOrderInvoice())
+	admin.GET("/orders/:id/packing-slip", controllers.GetOrderPackingSlip())
+	admin.GET("/shipments/:id/packing-slip", controllers.GetShipmentPackingSlip())
+	admin.GET("/exports/:dataset", controllers.ExportOrders())
+	admin.GET("/exports/:dataset/columns", controllers.GetExportColumns())
+	admin.GET("/export-jobs", controllers.GetExportJobs())
+	admin.GET("/export-jobs/:id", controllers.GetExportJob())
+	admin.GET("/export-jobs/:id/download", controllers.DownloadExportJob())
+	admin.DELETE("/export-jobs/:id", controllers.DeleteExportJob())
+	admin.PATCH("/update-order-status", controllers.UpdateOrderStatus())
+	admin.GET("/view-returns", controllers.GetAllReturns())
+	admin.PATCH("/returns/:id/receive", controllers.ReceiveReturn())
+	admin.PATCH("/returns/:id/approve", controllers.ApproveReturn())
+	admin.PATCH("/returns/:id/reject", controllers.RejectReturn())
+	admin.GET("/coupons", controllers.GetCoupons())
+	admin.POST("/coupons", controllers.CreateCoupon())
+	admin.PUT("/coupons/:id", controllers.UpdateCoupon())
+	admin.GET("/promotions", controllers.GetPromotions())
+	admin.POST("/promotions", controllers.CreatePromotion())
+	admin.PUT("/promotions/:id", controllers.UpdatePromotion())
+	admin.GET("/tax-rates", controllers.GetTaxRates())
+	admin.POST("/tax-rates", controllers.CreateTaxRate())
+	admin.PUT("/tax-rates/:id", controllers.UpdateTaxRate())
+	admin.DELETE("/tax-rates/:id", controllers.DeleteTaxRate())
+	admin.GET("/shipping-zones", controllers.GetShippingZones())
+	admin.POST("/shipping-zones", controllers.CreateShippingZone())
+	admin.PUT("/shipping-zones/:id", controllers.UpdateShippingZone())
+	admin.DELETE("/shipping-zones/:id", controllers.DeleteShippingZone())
+	admin.GET("/orders/:id/shipments", controllers.GetOrderShipments())
+	admin.POST("/orders/:id/shipments", controllers.CreateShipment())
+	admin.POST("/shipments/:id/events", controllers.RecordTrackingEvent())
+	admin.POST("/shipments/:id/sync", controllers.SyncShipment())
+	admin.POST("/shipments/:id/cancel", controllers.CancelShipment())
+	admin.GET("/carriers", controllers.GetCarriers())
+	admin.GET("/view-orders", controllers.GetAllOrders())
+	admin.POST("/add-product", controllers.ProductAdderAdmin())
+	admin.PATCH("/update-product", controllers.ProductUpdaterAdmin())
 
 	router.Use(middleware.Authorization())
 
 	router.GET("/user/list-cart", controllers.GetItemsFromCart())
-	router.POST("/user/add-address", controllers.AddAddress())
-	router.PATCH("/user/edit-home-address", controllers.EditHomeAddress())
-	router.PATCH("/user/edit-work-address", controllers.EditWorkAddress())
-	router.DELETE("/user/delete-addresses", controllers.DeleteAddress())
+	router.GET("/user/addresses", controllers.GetAddresses())
+	router.POST("/user/addresses", controllers.AddAddress())
+	router.PUT("/user/addresses/:id", controllers.EditAddress())
+	router.DELETE("/user/addresses/:id", controllers.DeleteAddress())
 
 	router.PATCH("/user/add-to-cart", app.AddToCart())
 	router.PATCH("/user/remove-item", app.RemoveItem())
-	router.GET("/user/cart-checkout", app.BuyFromCart())
-	// router.GET("/user/instant-buy", app.InstantBuy())
+	router.POST("/user/cart/coupon", controllers.ApplyCoupon())
+	router.DELETE("/user/cart/coupon", controllers.RemoveCoupon())
+	router.GET("/user/shipping-quote", controllers.GetShippingQuote())
+	idempotency := middleware.Idempotency(database.IdempotencyData(database.Client, "IdempotencyKeys"))
+	router.POST("/user/cart-checkout", idempotency, app.BuyFromCart())
+	router.POST("/user/instant-buy", idempotency, app.InstantBuy())
+
+	router.GET("/user/orders", controllers.GetUserOrders())
+	router.GET("/user/orders/:id", controllers.GetUserOrder())
+	router.POST("/user/orders/:id/cancel", controllers.CancelUserOrder())
+	router.POST("/user/orders/:id/returns", controllers.RequestReturn())
+	router.GET("/user/orders/:id/shipments", controllers.GetUserShipments())
+	router.GET("/user/orders/:id/invoice", controllers.GetUserInvoice())
+	router.GET("/user/returns", controllers.GetUserReturns())
 }
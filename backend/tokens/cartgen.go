@@ -0,0 +1,44 @@
+package token
+
+import (
+	"time"
+
+	"backend/database"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type CartClaims struct {
+	CartId string
+	jwt.StandardClaims
+}
+
+func CartTokenGenerator(cartId string) (string, error) {
+	claims := &CartClaims{
+		CartId: cartId,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Local().Add(database.GuestCartTTL).Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+}
+
+func ValidateCartToken(signedToken string) (cartId string, msg string) {
+	token, err := jwt.ParseWithClaims(signedToken, &CartClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(SECRET_KEY), nil
+	})
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+	claims, ok := token.Claims.(*CartClaims)
+	if !ok || claims.CartId == "" {
+		msg = "The cart token is invalid"
+		return
+	}
+	if claims.ExpiresAt < time.Now().Local().Unix() {
+		msg = "The cart token is expired"
+		return
+	}
+	return claims.CartId, msg
+}
@@ -20,18 +20,20 @@ type SignedDetails struct {
 	FirstName string
 	LastName  string
 	Uid       string
+	Role      string
 	jwt.StandardClaims
 }
 
 var UserData *mongo.Collection = database.UserData(database.Client, "Users")
 var SECRET_KEY = os.Getenv("SECRET_LOVE")
 
-func TokenGenerator(phone string, firstName string, lastName string, uid string) (signedToken string, signedRefreshToken string, err error) {
+func TokenGenerator(phone string, firstName string, lastName string, uid string, role string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Phone:     phone,
 		FirstName: firstName,
 		LastName:  lastName,
 		Uid:       uid,
+		Role:      role,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
 		},
@@ -63,7 +65,7 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 		return
 	}
 	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
+	if !ok || claims.Uid == "" {
 		msg = "The token is invalid"
 		return
 	}